@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/kballard/go-shellquote"
+)
+
+const affectedTasksEnvKey = "BITRISE_GRADLE_AFFECTED_TASKS"
+
+var (
+	// Like: project(":core:data"), project(path: ':core:data') or project(path = ":core:data")
+	projectDependencyRegexp = regexp.MustCompile(`project\s*\(\s*(?:path\s*[:=]\s*)?["'](:[^"']*)["']`)
+	// Type-safe project accessors, like: projects.core.dataSource
+	projectAccessorRegexp = regexp.MustCompile(`\bprojects((?:\.\w+)+)`)
+)
+
+// changedFiles returns the files changed since the merge base of baseRef and HEAD, relative to the repository root.
+func changedFiles(repoRoot, baseRef string) ([]string, error) {
+	cmd := exec.Command("git", "diff", "--name-only", "--no-renames", baseRef+"...HEAD")
+	cmd.Dir = repoRoot
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("git diff failed (is %s fetched?): %s", baseRef, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+// projectAccessor returns the type-safe accessor of the project path, like .core.dataSource for :core:data-source.
+func projectAccessor(pth string) string {
+	var b strings.Builder
+	for _, name := range strings.Split(strings.TrimPrefix(pth, ":"), ":") {
+		b.WriteString(".")
+		for i, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' }) {
+			if i > 0 {
+				part = strings.ToUpper(part[:1]) + part[1:]
+			}
+			b.WriteString(part)
+		}
+	}
+	return b.String()
+}
+
+// moduleDependencies returns the modules each module depends on, by their project(...) and projects.* references.
+func moduleDependencies(rootDir string, model projectModel) map[string][]string {
+	modulesByAccessor := map[string]string{}
+	known := map[string]bool{}
+	for _, module := range model.Modules {
+		modulesByAccessor[projectAccessor(module.Path)] = module.Path
+		known[module.Path] = true
+	}
+
+	dependencies := map[string][]string{}
+	for _, module := range model.Modules {
+		if module.BuildFile == "" || module.Path == ":" {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(rootDir, module.BuildFile))
+		if err != nil {
+			log.Warnf("Failed to read %s: %s", module.BuildFile, err)
+			continue
+		}
+		script := stripComments(string(content))
+
+		var references []string
+		for _, match := range projectDependencyRegexp.FindAllStringSubmatch(script, -1) {
+			references = append(references, match[1])
+		}
+		for _, match := range projectAccessorRegexp.FindAllStringSubmatch(script, -1) {
+			references = append(references, modulesByAccessor[match[1]])
+		}
+
+		for _, reference := range references {
+			if known[reference] && reference != module.Path && !sliceContains(dependencies[module.Path], reference) {
+				dependencies[module.Path] = append(dependencies[module.Path], reference)
+			}
+		}
+	}
+	return dependencies
+}
+
+// moduleOfFile returns the module containing the file (relative to the root project): the one with the deepest directory.
+func moduleOfFile(model projectModel, rel string) string {
+	owner, ownerDepth := ":", -1
+	for _, module := range model.Modules {
+		if module.Path == ":" {
+			continue
+		}
+		if rel == module.Dir || strings.HasPrefix(rel, module.Dir+string(os.PathSeparator)) {
+			if depth := strings.Count(module.Dir, string(os.PathSeparator)); depth > ownerDepth {
+				owner, ownerDepth = module.Path, depth
+			}
+		}
+	}
+	return owner
+}
+
+// fullBuildReason returns why a change of the file affects every module, or an empty string if it doesn't:
+// the root build scripts, version catalogs and properties, the build logic (buildSrc and the included builds)
+// and the other files of the root project, as the root project is not a module which could be selected.
+// The build scripts of the modules only affect their module.
+func fullBuildReason(model projectModel, rel string) string {
+	for _, build := range append([]string{"buildSrc"}, model.IncludedBuilds...) {
+		if rel == build || strings.HasPrefix(rel, build+string(os.PathSeparator)) {
+			return fmt.Sprintf("the build logic changed (%s)", rel)
+		}
+	}
+	if strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	isRootFile := !strings.Contains(rel, string(os.PathSeparator))
+	if (isRootFile && isCacheKeyFile(rel)) || strings.HasPrefix(rel, "gradle"+string(os.PathSeparator)) {
+		return fmt.Sprintf("a root build file changed (%s)", rel)
+	}
+	if moduleOfFile(model, rel) == ":" {
+		return fmt.Sprintf("a file of the root project changed (%s)", rel)
+	}
+	return ""
+}
+
+// findAffectedModules returns the modules containing the changed files (relative to the root project) and the modules depending on them,
+// or the reason why the full build is needed.
+func findAffectedModules(rootDir string, model projectModel, files []string) ([]string, string) {
+	changed := map[string]bool{}
+	for _, rel := range files {
+		if reason := fullBuildReason(model, rel); reason != "" {
+			return nil, reason
+		}
+		if strings.HasPrefix(rel, "..") {
+			continue
+		}
+		changed[moduleOfFile(model, rel)] = true
+	}
+
+	dependents := map[string][]string{}
+	for module, dependencies := range moduleDependencies(rootDir, model) {
+		for _, dependency := range dependencies {
+			dependents[dependency] = append(dependents[dependency], module)
+		}
+	}
+
+	affected := map[string]bool{}
+	var queue []string
+	for module := range changed {
+		queue = append(queue, module)
+	}
+	for len(queue) > 0 {
+		module := queue[0]
+		queue = queue[1:]
+		if affected[module] {
+			continue
+		}
+		affected[module] = true
+		queue = append(queue, dependents[module]...)
+	}
+
+	var modules []string
+	for _, module := range model.Modules {
+		if affected[module.Path] && module.BuildFile != "" {
+			modules = append(modules, module.Path)
+		}
+	}
+	sort.Strings(modules)
+	return modules, ""
+}
+
+// expandAffectedTasks runs the tasks in the affected modules only, like assembleDebug to :app:assembleDebug :core:assembleDebug.
+// Options (and their values, like -x lint) and the tasks which already have a project path are kept.
+func expandAffectedTasks(gradleTasks string, modules []string) (string, error) {
+	args, err := shellquote.Split(gradleTasks)
+	if err != nil {
+		return "", err
+	}
+
+	var expanded []string
+	hasTask := false
+	for i, arg := range args {
+		isOptionValue := i > 0 && (args[i-1] == "-x" || args[i-1] == "--exclude-task" || args[i-1] == "--tests")
+		switch {
+		case strings.HasPrefix(arg, "-") || isOptionValue:
+			expanded = append(expanded, arg)
+		case strings.Contains(arg, ":"):
+			expanded = append(expanded, arg)
+			hasTask = true
+		default:
+			for _, module := range modules {
+				expanded = append(expanded, gradleTaskPath(module, arg))
+				hasTask = true
+			}
+		}
+	}
+
+	if !hasTask {
+		return "", nil
+	}
+	return shellquote.Join(expanded...), nil
+}
+
+// selectAffectedTasks returns the tasks to run for the changes since baseRef.
+func selectAffectedTasks(buildRootAbs, baseRef, gradleTasks string) (string, error) {
+	model, err := parseProjectModel(buildRootAbs)
+	if err != nil {
+		return "", err
+	}
+
+	repoRoot := repositoryRoot(buildRootAbs)
+	files, err := changedFiles(repoRoot, baseRef)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("%d files changed since %s", len(files), baseRef)
+
+	// git returns the repository root with the symlinks resolved
+	buildRootReal, err := filepath.EvalSymlinks(buildRootAbs)
+	if err != nil {
+		return "", err
+	}
+
+	var relFiles []string
+	for _, file := range files {
+		rel, err := filepath.Rel(buildRootReal, filepath.Join(repoRoot, file))
+		if err != nil {
+			return "", err
+		}
+		relFiles = append(relFiles, rel)
+	}
+
+	tasks := gradleTasks
+	modules, reason := findAffectedModules(buildRootAbs, *model, relFiles)
+	if reason != "" {
+		log.Warnf("Running the full build, as %s", reason)
+	} else {
+		log.Printf("Affected modules (%d of %d):", len(modules), len(model.Modules)-1)
+		for _, module := range modules {
+			log.Printf("  %s", module)
+		}
+
+		if tasks, err = expandAffectedTasks(gradleTasks, modules); err != nil {
+			return "", err
+		}
+	}
+
+	return tasks, nil
+}
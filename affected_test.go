@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeProjectFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		pth := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0755))
+		require.NoError(t, os.WriteFile(pth, []byte(content), 0644))
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+var affectedTestProject = map[string]string{
+	"settings.gradle.kts":           "include(\":app\", \":feature:login\", \":core:data-source\", \":core:ui\", \":server\")\n",
+	"build.gradle.kts":              "",
+	"app/build.gradle.kts":          "dependencies {\n    implementation(project(\":feature:login\"))\n    implementation(projects.core.ui)\n}\n",
+	"feature/login/build.gradle":    "dependencies {\n    implementation project(path: ':core:data-source')\n}\n",
+	"core/data-source/build.gradle": "plugins {\n    id 'java-library'\n}\n",
+	"core/ui/build.gradle.kts":      "dependencies {\n    // implementation(project(\":core:data-source\"))\n}\n",
+	"server/build.gradle":           "dependencies {\n    implementation projects.core.dataSource\n}\n",
+}
+
+func TestFindAffectedModules(t *testing.T) {
+	root := t.TempDir()
+	writeProjectFiles(t, root, affectedTestProject)
+	model, err := parseProjectModel(root)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		files      []string
+		wantReason string
+		want       []string
+	}{
+		{
+			name:  "leaf module",
+			files: []string{"app/src/main/AndroidManifest.xml"},
+			want:  []string{":app"},
+		},
+		{
+			name:  "dependents are affected",
+			files: []string{"core/data-source/src/main/java/Data.java"},
+			want:  []string{":app", ":core:data-source", ":feature:login", ":server"},
+		},
+		{
+			name:  "files outside of the build root are ignored",
+			files: []string{"../ios/App.swift", "core/ui/src/main/kotlin/Button.kt"},
+			want:  []string{":app", ":core:ui"},
+		},
+		{
+			name:  "nothing affected",
+			files: []string{"../ios/App.swift"},
+			want:  nil,
+		},
+		{
+			name:  "module build file",
+			files: []string{"feature/login/build.gradle", "core/ui/gradle.properties"},
+			want:  []string{":app", ":core:ui", ":feature:login"},
+		},
+		{
+			name:       "root build file",
+			files:      []string{"app/src/main/AndroidManifest.xml", "gradle/libs.versions.toml"},
+			wantReason: "a root build file changed (gradle/libs.versions.toml)",
+		},
+		{
+			name:       "root settings file",
+			files:      []string{"settings.gradle.kts"},
+			wantReason: "a root build file changed (settings.gradle.kts)",
+		},
+		{
+			name:       "root project file",
+			files:      []string{"app/src/main/AndroidManifest.xml", "src/main/java/Root.java"},
+			wantReason: "a file of the root project changed (src/main/java/Root.java)",
+		},
+		{
+			name:       "build logic",
+			files:      []string{"buildSrc/src/main/kotlin/Conventions.kt"},
+			wantReason: "the build logic changed (buildSrc/src/main/kotlin/Conventions.kt)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modules, reason := findAffectedModules(root, *model, tt.files)
+			require.Equal(t, tt.wantReason, reason)
+			require.Equal(t, tt.want, modules)
+		})
+	}
+}
+
+func TestExpandAffectedTasks(t *testing.T) {
+	tasks, err := expandAffectedTasks("assembleDebug -x lint testDebugUnitTest :app:bundleRelease", []string{":app", ":core"})
+	require.NoError(t, err)
+	require.Equal(t, ":app:assembleDebug :core:assembleDebug -x lint :app:testDebugUnitTest :core:testDebugUnitTest :app:bundleRelease", tasks)
+
+	tasks, err = expandAffectedTasks("assembleDebug --continue", nil)
+	require.NoError(t, err)
+	require.Equal(t, "", tasks)
+}
+
+func TestSelectAffectedTasks(t *testing.T) {
+	repo := t.TempDir()
+	root := filepath.Join(repo, "android")
+	writeProjectFiles(t, root, affectedTestProject)
+	runGit(t, repo, "init", "-q")
+	runGit(t, repo, "add", "-A")
+	runGit(t, repo, "commit", "-q", "-m", "initial")
+	runGit(t, repo, "branch", "base")
+
+	writeProjectFiles(t, root, map[string]string{"feature/login/src/main/kotlin/Login.kt": "class Login"})
+	runGit(t, repo, "add", "-A")
+	runGit(t, repo, "commit", "-q", "-m", "login")
+
+	tasks, err := selectAffectedTasks(root, "base", "assembleDebug")
+	require.NoError(t, err)
+	require.Equal(t, ":app:assembleDebug :feature:login:assembleDebug", tasks)
+
+	writeProjectFiles(t, root, map[string]string{"gradle.properties": "org.gradle.caching=true"})
+	runGit(t, repo, "add", "-A")
+	runGit(t, repo, "commit", "-q", "-m", "properties")
+
+	tasks, err = selectAffectedTasks(root, "base", "assembleDebug")
+	require.NoError(t, err)
+	require.Equal(t, "assembleDebug", tasks)
+
+	_, err = selectAffectedTasks(root, "missing", "assembleDebug")
+	require.Error(t, err)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	utilscache "github.com/bitrise-io/go-steputils/cache"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+const localBuildCacheInitScriptName = "local-build-cache.init.gradle"
+
+// The retention of the build cache entries is configured with the cache cleanup settings on Gradle 8+,
+// DirectoryBuildCache.removeUnusedEntriesAfterDays is deprecated there.
+const localBuildCacheInitScriptTemplate = `def bitriseBuildCacheDir = {{DIRECTORY}}
+def bitriseRetentionDays = {{RETENTION_DAYS}}
+def bitriseCacheCleanupSupported = GradleVersion.current().baseVersion >= GradleVersion.version('8.0')
+
+if (bitriseCacheCleanupSupported) {
+    beforeSettings { settings ->
+        settings.caches {
+            buildCache.removeUnusedEntriesAfterDays = bitriseRetentionDays
+        }
+    }
+}
+
+settingsEvaluated { settings ->
+    settings.buildCache {
+        local {
+            enabled = true
+            directory = new File(bitriseBuildCacheDir)
+            if (!bitriseCacheCleanupSupported) {
+                removeUnusedEntriesAfterDays = bitriseRetentionDays
+            }
+        }
+    }
+}
+`
+
+func localBuildCacheInitScript(dir string, retentionDays int) string {
+	script := strings.ReplaceAll(localBuildCacheInitScriptTemplate, "{{DIRECTORY}}", groovyString(dir))
+	return strings.ReplaceAll(script, "{{RETENTION_DAYS}}", strconv.Itoa(retentionDays))
+}
+
+// buildCacheArgs enables the build cache, unless the Gradle options already decide about it
+// (--build-cache or --no-build-cache).
+func buildCacheArgs(gradleOptions string) []string {
+	if strings.Contains(gradleOptions, "build-cache") {
+		return nil
+	}
+	return []string{"--build-cache"}
+}
+
+// buildCacheEnabled reports whether the build cache is turned on by the Gradle options or by the org.gradle.caching
+// property of the user's or the project's gradle.properties (the user's takes precedence, like in Gradle).
+func buildCacheEnabled(buildRootAbs, gradleUserHome, gradleOptions string) bool {
+	for _, option := range strings.Fields(gradleOptions) {
+		switch {
+		case option == "--build-cache" || option == "-Dorg.gradle.caching=true":
+			return true
+		case option == "--no-build-cache" || option == "-Dorg.gradle.caching=false":
+			return false
+		}
+	}
+
+	for _, pth := range []string{
+		filepath.Join(gradleUserHome, "gradle.properties"),
+		filepath.Join(buildRootAbs, "gradle.properties"),
+	} {
+		properties, err := readProperties(pth)
+		if err != nil {
+			continue
+		}
+		if value, ok := properties["org.gradle.caching"]; ok {
+			return value == "true"
+		}
+	}
+
+	return false
+}
+
+// collectLocalBuildCache registers the step managed local build cache for caching:
+// it is not bound to the dependency cache indicator, as its entries change with every build.
+func collectLocalBuildCache(dir string, cacheLevel utilscache.Level) error {
+	if cacheLevel == utilscache.LevelNone {
+		return nil
+	}
+
+	if exist, err := pathutil.IsDirExists(dir); err != nil {
+		return err
+	} else if !exist {
+		return nil
+	}
+
+	gradleCache := utilscache.New()
+	gradleCache.IncludePath(dir)
+	return gradleCache.Commit()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalBuildCacheInitScript(t *testing.T) {
+	script := localBuildCacheInitScript("/root/.gradle-build-cache", 7)
+	require.Contains(t, script, "def bitriseBuildCacheDir = '/root/.gradle-build-cache'\n")
+	require.Contains(t, script, "def bitriseRetentionDays = 7\n")
+}
+
+func TestBuildCacheArgs(t *testing.T) {
+	require.Equal(t, []string{"--build-cache"}, buildCacheArgs("--stacktrace"))
+	require.Nil(t, buildCacheArgs("--no-build-cache"))
+}
+
+func TestBuildCacheEnabled(t *testing.T) {
+	projectDir := t.TempDir()
+	gradleUserHome := t.TempDir()
+
+	require.False(t, buildCacheEnabled(projectDir, gradleUserHome, ""))
+	require.True(t, buildCacheEnabled(projectDir, gradleUserHome, "--stacktrace --build-cache"))
+
+	writeTestFile(t, filepath.Join(projectDir, "gradle.properties"), "org.gradle.jvmargs=-Xmx2g\norg.gradle.caching=true\n")
+	require.True(t, buildCacheEnabled(projectDir, gradleUserHome, ""))
+	require.False(t, buildCacheEnabled(projectDir, gradleUserHome, "--no-build-cache"))
+
+	writeTestFile(t, filepath.Join(gradleUserHome, "gradle.properties"), "org.gradle.caching=false\n")
+	require.False(t, buildCacheEnabled(projectDir, gradleUserHome, ""))
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	utilscache "github.com/bitrise-io/go-steputils/cache"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+const (
+	cacheIndicatorFileName = "gradle.deps"
+	cacheIndicatorDirName  = "gradle-runner-cache"
+)
+
+var (
+	// Like: distributionUrl=https\://services.gradle.org/distributions/gradle-8.2.1-bin.zip
+	distributionURLVersionRegexp = regexp.MustCompile(`gradle-([^/]+?)-(?:bin|all)\.zip$`)
+	// Gradle version named dirs, like: ~/.gradle/caches/8.2.1
+	gradleVersionDirRegexp = regexp.MustCompile(`^\d+\.\d+(\.\d+)?(-[\w.-]+)?$`)
+)
+
+// cacheIndicatorPath returns a stable, step-owned path for the cache indicator of the project:
+// the cache indicator must not be written into the repository, as it would leave an untracked file behind.
+func cacheIndicatorPath(projectRoot string) string {
+	projectHash := fmt.Sprintf("%x", md5.Sum([]byte(projectRoot)))
+	return filepath.Join(os.TempDir(), cacheIndicatorDirName, projectHash[:12], cacheIndicatorFileName)
+}
+
+func gradleWrapperPropertiesPath(gradlewPath string) string {
+	return filepath.Join(filepath.Dir(gradlewPath), "gradle", "wrapper", "gradle-wrapper.properties")
+}
+
+func readProperties(pth string) (map[string]string, error) {
+	content, err := os.ReadFile(pth)
+	if err != nil {
+		return nil, err
+	}
+
+	properties := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		properties[strings.TrimSpace(key)] = strings.ReplaceAll(strings.TrimSpace(value), `\:`, ":")
+	}
+
+	return properties, scanner.Err()
+}
+
+// gradleWrapperVersion returns the Gradle version of the wrapper's distributionUrl,
+// without running gradlew (which would download the distribution if it is missing).
+func gradleWrapperVersion(gradlewPath string) (string, error) {
+	propertiesPth := gradleWrapperPropertiesPath(gradlewPath)
+	properties, err := readProperties(propertiesPth)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", propertiesPth, err)
+	}
+
+	distributionURL := properties["distributionUrl"]
+	match := distributionURLVersionRegexp.FindStringSubmatch(distributionURL)
+	if match == nil {
+		return "", fmt.Errorf("failed to find Gradle version in distributionUrl: %s", distributionURL)
+	}
+
+	return match[1], nil
+}
+
+// committedContent returns the content of the file as committed to git, if the file is tracked and modified:
+// changes made during the build (like a version bump) should not invalidate the cache.
+func committedContent(pth string) ([]byte, bool) {
+	dir, name := filepath.Split(pth)
+
+	cmd := exec.Command("git", "diff", "--quiet", "HEAD", "--", name)
+	cmd.Dir = dir
+	if err := cmd.Run(); err == nil {
+		return nil, false
+	}
+
+	cmd = exec.Command("git", "show", "HEAD:./"+name)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, false
+	}
+
+	return out, true
+}
+
+func gradleCacheIncludePaths(homeDir, gradleUserHome, projectRoot, indicatorPth string, cacheLevel utilscache.Level) ([]string, error) {
+	includePths := []string{
+		fmt.Sprintf("%s -> %s", gradleUserHome, indicatorPth),
+		fmt.Sprintf("%s -> %s", filepath.Join(homeDir, ".kotlin"), indicatorPth),
+		fmt.Sprintf("%s -> %s", filepath.Join(homeDir, ".m2"), indicatorPth),
+	}
+
+	if cacheLevel != utilscache.LevelAll {
+		return includePths, nil
+	}
+
+	includePths = append(includePths, fmt.Sprintf("%s -> %s", filepath.Join(homeDir, ".android", "build-cache"), indicatorPth))
+	if err := filepath.Walk(projectRoot, func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to walk %s: %w", pth, err)
+		}
+
+		if info.IsDir() && (info.Name() == "build" || info.Name() == ".gradle") {
+			includePths = append(includePths, pth)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to collect build cache: %w", err)
+	}
+
+	return includePths, nil
+}
+
+// oldGradleVersionExcludes excludes the version named entries of dir which belong to another Gradle version,
+// like ~/.gradle/caches/5.1.1.
+func oldGradleVersionExcludes(dir, currentGradleVersion string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var excludes []string
+	for _, entry := range entries {
+		if gradleVersionDirRegexp.MatchString(entry.Name()) && entry.Name() != currentGradleVersion {
+			excludes = append(excludes, "!"+filepath.Join(dir, entry.Name()))
+		}
+	}
+	return excludes
+}
+
+func gradleCacheExcludePaths(gradleUserHome, projectRoot, gradleVersion string) []string {
+	excludePths := []string{
+		// excludes Gradle daemon logs, like: ~/.gradle/daemon/6.1.1/daemon-3122.out.log
+		"!" + filepath.Join(gradleUserHome, "daemon", "*", "daemon-*.out.log"),
+		"~/.android/build-cache/**",
+		"*.lock",
+		"*.bin",
+		"*/build/*.json",
+		"*/build/*.html",
+		"*/build/*.xml",
+		"*/build/*.properties",
+		"*/build/*/zip-cache/*",
+		"*.log",
+		"*.txt",
+		"*.rawproto",
+		"!*.ap_",
+		"!*.apk",
+	}
+
+	if gradleVersion == "" {
+		return excludePths
+	}
+
+	// exclude old wrappers, like ~/.gradle/wrapper/dists/gradle-5.1.1-all
+	wrapperDistsDir := filepath.Join(gradleUserHome, "wrapper", "dists")
+	if entries, err := os.ReadDir(wrapperDistsDir); err == nil {
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), "gradle-"+gradleVersion+"-") {
+				excludePths = append(excludePths, "!"+filepath.Join(wrapperDistsDir, entry.Name()))
+			}
+		}
+	}
+
+	excludePths = append(excludePths, oldGradleVersionExcludes(filepath.Join(gradleUserHome, "caches"), gradleVersion)...)
+	excludePths = append(excludePths, oldGradleVersionExcludes(filepath.Join(gradleUserHome, "daemon"), gradleVersion)...)
+	excludePths = append(excludePths, oldGradleVersionExcludes(filepath.Join(projectRoot, ".gradle"), gradleVersion)...)
+
+	return excludePths
+}
+
+// gradleCacheItems writes the cache indicator and returns the paths to include in and exclude from the cache.
+func gradleCacheItems(homeDir, gradleUserHome, projectRoot, gradleVersion, indicatorPth, indicatorContent string, cacheLevel utilscache.Level) ([]string, []string, error) {
+	if err := os.MkdirAll(filepath.Dir(indicatorPth), 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create cache indicator dir: %w", err)
+	}
+	if err := os.WriteFile(indicatorPth, []byte(indicatorContent), 0644); err != nil {
+		return nil, nil, fmt.Errorf("failed to write cache indicator: %w", err)
+	}
+
+	includePths, err := gradleCacheIncludePaths(homeDir, gradleUserHome, projectRoot, indicatorPth, cacheLevel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return includePths, gradleCacheExcludePaths(gradleUserHome, projectRoot, gradleVersion), nil
+}
+
+// collectGradleCache registers the Gradle caches for the Cache:Push step,
+// the caches of Gradle versions other than gradleVersion are excluded (unless it is unknown).
+func collectGradleCache(projectRoot, gradleVersion, gradleUserHome string, cacheLevel utilscache.Level) error {
+	if cacheLevel == utilscache.LevelNone {
+		return nil
+	}
+
+	entries, err := cacheKeyEntries(projectRoot)
+	if err != nil {
+		return err
+	}
+	manifest := cacheKeyManifest(entries)
+	key := cacheKey(manifest)
+
+	log.Printf("Cache key: %s, computed from:", key)
+	for _, entry := range entries {
+		log.Printf("  %s  %s", entry.Hash[:12], entry.Path)
+	}
+	if err := exportEnvironmentWithEnvman(cacheKeyEnvKey, key); err != nil {
+		return fmt.Errorf("failed to export environment (%s): %w", cacheKeyEnvKey, err)
+	}
+
+	includePths, excludePths, err := gradleCacheItems(pathutil.UserHomeDir(), gradleUserHome, projectRoot, gradleVersion, cacheIndicatorPath(projectRoot), manifest, cacheLevel)
+	if err != nil {
+		return err
+	}
+
+	gradleCache := utilscache.New()
+	gradleCache.IncludePath(includePths...)
+	gradleCache.ExcludePath(excludePths...)
+	if err := gradleCache.Commit(); err != nil {
+		return fmt.Errorf("failed to commit cache paths: %w", err)
+	}
+
+	return nil
+}
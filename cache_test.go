@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	utilscache "github.com/bitrise-io/go-steputils/cache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGradleWrapperVersion(t *testing.T) {
+	projectDir := t.TempDir()
+	gradlewPath := filepath.Join(projectDir, "gradlew")
+
+	_, err := gradleWrapperVersion(gradlewPath)
+	require.Error(t, err)
+
+	writeTestFile(t, gradleWrapperPropertiesPath(gradlewPath), `#Mon Jan 01 00:00:00 UTC 2024
+distributionBase=GRADLE_USER_HOME
+distributionPath=wrapper/dists
+distributionUrl=https\://services.gradle.org/distributions/gradle-8.2.1-all.zip
+zipStoreBase=GRADLE_USER_HOME
+zipStorePath=wrapper/dists
+`)
+
+	version, err := gradleWrapperVersion(gradlewPath)
+	require.NoError(t, err)
+	require.Equal(t, "8.2.1", version)
+}
+
+func TestGradleCacheExcludePaths(t *testing.T) {
+	homeDir := t.TempDir()
+	projectDir := t.TempDir()
+	for _, dir := range []string{
+		".gradle/wrapper/dists/gradle-8.2-bin",
+		".gradle/wrapper/dists/gradle-8.2.1-all",
+		".gradle/caches/8.2",
+		".gradle/caches/8.2.1",
+		".gradle/caches/modules-2",
+		".gradle/daemon/8.2",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(homeDir, dir), 0755))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(projectDir, ".gradle", "8.2"), 0755))
+
+	excludes := gradleCacheExcludePaths(filepath.Join(homeDir, ".gradle"), projectDir, "8.2.1")
+	require.Subset(t, excludes, []string{
+		"!" + filepath.Join(homeDir, ".gradle/wrapper/dists/gradle-8.2-bin"),
+		"!" + filepath.Join(homeDir, ".gradle/caches/8.2"),
+		"!" + filepath.Join(homeDir, ".gradle/daemon/8.2"),
+		"!" + filepath.Join(projectDir, ".gradle/8.2"),
+	})
+	require.NotContains(t, excludes, "!"+filepath.Join(homeDir, ".gradle/wrapper/dists/gradle-8.2.1-all"))
+	require.NotContains(t, excludes, "!"+filepath.Join(homeDir, ".gradle/caches/8.2.1"))
+	require.NotContains(t, excludes, "!"+filepath.Join(homeDir, ".gradle/caches/modules-2"))
+}
+
+func TestGradleCacheItemsLeavesProjectUntouched(t *testing.T) {
+	homeDir := t.TempDir()
+	projectDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(projectDir, "app", "build"), 0755))
+
+	indicatorPth := filepath.Join(t.TempDir(), cacheIndicatorFileName)
+	includes, _, err := gradleCacheItems(homeDir, filepath.Join(homeDir, ".gradle"), projectDir, "8.2.1", indicatorPth, "manifest", utilscache.LevelAll)
+	require.NoError(t, err)
+
+	require.Contains(t, includes, filepath.Join(homeDir, ".gradle")+" -> "+indicatorPth)
+	require.Contains(t, includes, filepath.Join(projectDir, "app", "build"))
+
+	indicator, err := os.ReadFile(indicatorPth)
+	require.NoError(t, err)
+	require.Equal(t, "manifest", string(indicator))
+
+	_, err = os.Stat(filepath.Join(projectDir, cacheIndicatorFileName))
+	require.True(t, os.IsNotExist(err))
+}
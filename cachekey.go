@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const cacheKeyEnvKey = "BITRISE_GRADLE_CACHE_KEY"
+
+// Like: includeBuild("build-logic") or includeBuild '../shared'
+var includeBuildRegexp = regexp.MustCompile(`includeBuild\s*\(?\s*["']([^"']+)["']`)
+
+type cacheKeyEntry struct {
+	// Path is relative to the project root.
+	Path string
+	Hash string
+}
+
+// isCacheKeyFile reports whether the file affects the resolved dependencies or the build logic:
+// build scripts, version catalogs, dependency lockfiles and Gradle properties.
+func isCacheKeyFile(name string) bool {
+	for _, suffix := range []string{".gradle", ".gradle.kts", ".versions.toml", ".lockfile"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return name == "gradle.properties" || name == "gradle-wrapper.properties"
+}
+
+// includedBuildDirs returns the included builds (includeBuild declarations of the settings script) outside of projectRoot,
+// builds inside of it (like build-logic or buildSrc) are already covered.
+func includedBuildDirs(projectRoot string) []string {
+	var dirs []string
+	for _, name := range []string{"settings.gradle", "settings.gradle.kts"} {
+		content, err := os.ReadFile(filepath.Join(projectRoot, name))
+		if err != nil {
+			continue
+		}
+
+		for _, match := range includeBuildRegexp.FindAllStringSubmatch(string(content), -1) {
+			dir := filepath.Clean(filepath.Join(projectRoot, match[1]))
+			if rel, err := filepath.Rel(projectRoot, dir); err == nil && !strings.HasPrefix(rel, "..") {
+				continue
+			}
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+func collectCacheKeyEntries(projectRoot, dir string, entries map[string]cacheKeyEntry) error {
+	return filepath.Walk(dir, func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to walk %s: %w", pth, err)
+		}
+
+		if info.IsDir() {
+			if pth != dir && (info.Name() == "build" || info.Name() == "node_modules" || strings.HasPrefix(info.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !isCacheKeyFile(info.Name()) {
+			return nil
+		}
+
+		content, committed := committedContent(pth)
+		if !committed {
+			if content, err = os.ReadFile(pth); err != nil {
+				log.Warnf("Failed to read %s: %s", pth, err)
+				return nil
+			}
+		}
+
+		rel, err := filepath.Rel(projectRoot, pth)
+		if err != nil {
+			return err
+		}
+		entries[rel] = cacheKeyEntry{Path: rel, Hash: fmt.Sprintf("%x", sha256.Sum256(content))}
+
+		return nil
+	})
+}
+
+// cacheKeyEntries returns the hashes of the files which determine the content of the dependency cache, sorted by path.
+func cacheKeyEntries(projectRoot string) ([]cacheKeyEntry, error) {
+	entriesByPath := map[string]cacheKeyEntry{}
+	for _, dir := range append([]string{projectRoot}, includedBuildDirs(projectRoot)...) {
+		if err := collectCacheKeyEntries(projectRoot, dir, entriesByPath); err != nil {
+			return nil, fmt.Errorf("failed to compute cache key: %w", err)
+		}
+	}
+
+	entries := make([]cacheKeyEntry, 0, len(entriesByPath))
+	for _, entry := range entriesByPath {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+
+	return entries, nil
+}
+
+// cacheKeyManifest lists the hashed files, like sha256sum does: it is used as the cache indicator,
+// so comparing the indicators of two builds shows why the cache changed.
+func cacheKeyManifest(entries []cacheKeyEntry) string {
+	var b strings.Builder
+	for _, entry := range entries {
+		b.WriteString(entry.Hash + "  " + filepath.ToSlash(entry.Path) + "\n")
+	}
+	return b.String()
+}
+
+func cacheKey(manifest string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(manifest)))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheKeyEntries(t *testing.T) {
+	root := t.TempDir()
+	projectDir := filepath.Join(root, "project")
+	for _, pth := range []string{
+		"settings.gradle.kts",
+		"build.gradle.kts",
+		"gradle.properties",
+		"gradle/libs.versions.toml",
+		"gradle/wrapper/gradle-wrapper.properties",
+		"app/build.gradle.kts",
+		"app/gradle.lockfile",
+		"app/src/main/AndroidManifest.xml",
+		"app/build/generated/build.gradle",
+		"buildSrc/build.gradle.kts",
+		"buildSrc/src/main/kotlin/android-conventions.gradle.kts",
+		".gradle/8.2/build.gradle",
+	} {
+		writeTestFile(t, filepath.Join(projectDir, pth), pth)
+	}
+	writeTestFile(t, filepath.Join(projectDir, "settings.gradle.kts"), `pluginManagement {
+    includeBuild("../shared-logic")
+}
+includeBuild("build-logic")
+include(":app")
+`)
+	writeTestFile(t, filepath.Join(root, "shared-logic", "build.gradle.kts"), "plugins {}")
+	writeTestFile(t, filepath.Join(root, "unrelated", "build.gradle.kts"), "plugins {}")
+
+	entries, err := cacheKeyEntries(projectDir)
+	require.NoError(t, err)
+
+	var paths []string
+	for _, entry := range entries {
+		paths = append(paths, filepath.ToSlash(entry.Path))
+	}
+	require.Equal(t, []string{
+		"../shared-logic/build.gradle.kts",
+		"app/build.gradle.kts",
+		"app/gradle.lockfile",
+		"build.gradle.kts",
+		"buildSrc/build.gradle.kts",
+		"buildSrc/src/main/kotlin/android-conventions.gradle.kts",
+		"gradle.properties",
+		"gradle/libs.versions.toml",
+		"gradle/wrapper/gradle-wrapper.properties",
+		"settings.gradle.kts",
+	}, paths)
+
+	key := cacheKey(cacheKeyManifest(entries))
+
+	writeTestFile(t, filepath.Join(projectDir, "gradle", "libs.versions.toml"), "[versions]\nkotlin = \"1.9.0\"\n")
+	entries, err = cacheKeyEntries(projectDir)
+	require.NoError(t, err)
+	require.NotEqual(t, key, cacheKey(cacheKeyManifest(entries)))
+}
+
+func TestCacheKeyManifest(t *testing.T) {
+	require.Equal(t, "aaa  app/build.gradle\nbbb  gradle.properties\n", cacheKeyManifest([]cacheKeyEntry{
+		{Path: filepath.Join("app", "build.gradle"), Hash: "aaa"},
+		{Path: "gradle.properties", Hash: "bbb"},
+	}))
+}
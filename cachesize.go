@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const cacheSizeEnvKey = "BITRISE_GRADLE_CACHE_SIZE_BYTES"
+
+type cacheSizeEntry struct {
+	Name string
+	Size int64
+}
+
+// pathsSize returns the total size of the paths matching the glob patterns, missing paths count as empty.
+func pathsSize(patterns ...string) (int64, error) {
+	var total int64
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, match := range matches {
+			size, err := dirSize(match)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return 0, err
+			}
+			total += size
+		}
+	}
+	return total, nil
+}
+
+// cacheSizeEntries breaks down the size of the cached dependency paths,
+// the sizes don't take the cache exclude patterns into account.
+func cacheSizeEntries(homeDir, gradleUserHome string) ([]cacheSizeEntry, error) {
+	gradleEntries := []struct {
+		name     string
+		patterns []string
+	}{
+		{"caches/modules-2", []string{filepath.Join(gradleUserHome, "caches", "modules-2")}},
+		{"caches/transforms", []string{
+			filepath.Join(gradleUserHome, "caches", "transforms-*"),
+			filepath.Join(gradleUserHome, "caches", "*", "transforms"),
+		}},
+		{"caches/build-cache-1", []string{filepath.Join(gradleUserHome, "caches", "build-cache-1")}},
+		{"wrapper/dists", []string{filepath.Join(gradleUserHome, "wrapper", "dists")}},
+	}
+	gradleUserHomeName := displayHomePath(homeDir, gradleUserHome)
+
+	gradleTotal, err := pathsSize(gradleUserHome)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []cacheSizeEntry
+	for _, entry := range gradleEntries {
+		size, err := pathsSize(entry.patterns...)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, cacheSizeEntry{Name: filepath.Join(gradleUserHomeName, entry.name), Size: size})
+		gradleTotal -= size
+	}
+	entries = append(entries, cacheSizeEntry{Name: gradleUserHomeName + " (other)", Size: gradleTotal})
+
+	for _, name := range []string{".m2", ".kotlin"} {
+		size, err := pathsSize(filepath.Join(homeDir, name))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, cacheSizeEntry{Name: "~/" + name, Size: size})
+	}
+
+	return entries, nil
+}
+
+func cacheSizeTotal(entries []cacheSizeEntry) int64 {
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+	return total
+}
+
+func printCacheSizeTable(entries []cacheSizeEntry, total int64) {
+	for _, entry := range entries {
+		percent := 0.0
+		if total > 0 {
+			percent = float64(entry.Size) / float64(total) * 100
+		}
+		log.Printf("  %-32s %12s %5.1f%%", entry.Name, formatBytes(entry.Size), percent)
+	}
+	log.Printf("  %-32s %12s", "Total", formatBytes(total))
+}
+
+func processCacheSize(homeDir, gradleUserHome string, budgetMB int) error {
+	entries, err := cacheSizeEntries(homeDir, gradleUserHome)
+	if err != nil {
+		return fmt.Errorf("failed to compute cache size: %w", err)
+	}
+
+	total := cacheSizeTotal(entries)
+	printCacheSizeTable(entries, total)
+
+	if budget := int64(budgetMB) * 1024 * 1024; budget > 0 && total > budget {
+		log.Warnf("The cache size (%s) exceeds the budget (%s), consider enabling prune_gradle_cache", formatBytes(total), formatBytes(budget))
+	}
+
+	if err := exportEnvironmentWithEnvman(cacheSizeEnvKey, strconv.FormatInt(total, 10)); err != nil {
+		return fmt.Errorf("failed to export environment (%s): %w", cacheSizeEnvKey, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheSizeEntries(t *testing.T) {
+	homeDir := t.TempDir()
+	for pth, size := range map[string]int{
+		".gradle/caches/modules-2/files-2.1/okio.jar":     100,
+		".gradle/caches/transforms-3/abc/classes.jar":     20,
+		".gradle/caches/8.8/transforms/def/classes.jar":   30,
+		".gradle/caches/build-cache-1/0a1b2c":             40,
+		".gradle/wrapper/dists/gradle-8.2-bin/gradle.zip": 50,
+		".gradle/caches/jars-9/buildSrc.jar":              5,
+		".m2/repository/lib.jar":                          7,
+	} {
+		writeTestFile(t, filepath.Join(homeDir, pth), string(make([]byte, size)))
+	}
+
+	entries, err := cacheSizeEntries(homeDir, filepath.Join(homeDir, ".gradle"))
+	require.NoError(t, err)
+	require.Equal(t, []cacheSizeEntry{
+		{Name: "~/.gradle/caches/modules-2", Size: 100},
+		{Name: "~/.gradle/caches/transforms", Size: 50},
+		{Name: "~/.gradle/caches/build-cache-1", Size: 40},
+		{Name: "~/.gradle/wrapper/dists", Size: 50},
+		{Name: "~/.gradle (other)", Size: 5},
+		{Name: "~/.m2", Size: 7},
+		{Name: "~/.kotlin", Size: 0},
+	}, entries)
+	require.Equal(t, int64(252), cacheSizeTotal(entries))
+}
+
+func TestCacheSizeEntriesCustomGradleUserHome(t *testing.T) {
+	homeDir := t.TempDir()
+	gradleUserHome := t.TempDir()
+	writeTestFile(t, filepath.Join(gradleUserHome, "caches/modules-2/files-2.1/okio.jar"), "jar")
+
+	entries, err := cacheSizeEntries(homeDir, gradleUserHome)
+	require.NoError(t, err)
+	require.Equal(t, cacheSizeEntry{Name: filepath.Join(gradleUserHome, "caches/modules-2"), Size: 3}, entries[0])
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	utilscache "github.com/bitrise-io/go-steputils/cache"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+const (
+	configurationCacheOff          = "off"
+	configurationCacheReasonPrefix = "configuration cache cannot be reused because "
+
+	configurationCacheReusedEnvKey             = "BITRISE_GRADLE_CONFIGURATION_CACHE_REUSED"
+	configurationCacheInvalidationReasonEnvKey = "BITRISE_GRADLE_CONFIGURATION_CACHE_INVALIDATION_REASON"
+)
+
+var (
+	// Like: Reusing configuration cache.
+	// or (Gradle 8.x): Configuration cache entry reused.
+	configurationCacheReusedRegexp = regexp.MustCompile(`^(Reusing configuration cache\.|Configuration cache entry reused)`)
+	// Like: Calculating task graph as no cached configuration is available for tasks: assembleDebug
+	// or: Calculating task graph as configuration cache cannot be reused because file 'app/build.gradle' has changed.
+	configurationCacheMissRegexp = regexp.MustCompile(`^Calculating task graph as (.+)$`)
+)
+
+type configurationCacheResult struct {
+	Reused bool
+	// Reason is why the configuration had to be calculated, empty if the cache was reused.
+	Reason string
+}
+
+// parseConfigurationCacheResult returns the outcome of the last configuration cache lookup in the Gradle output,
+// or nil if the configuration cache was not used.
+func parseConfigurationCacheResult(output string) *configurationCacheResult {
+	var result *configurationCacheResult
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+
+		if configurationCacheReusedRegexp.MatchString(line) {
+			result = &configurationCacheResult{Reused: true}
+			continue
+		}
+
+		if match := configurationCacheMissRegexp.FindStringSubmatch(line); match != nil {
+			reason := strings.TrimPrefix(match[1], configurationCacheReasonPrefix)
+			result = &configurationCacheResult{Reason: strings.TrimSuffix(reason, ".")}
+		}
+	}
+	return result
+}
+
+func configurationCacheArgs(mode, gradleOptions string) []string {
+	if mode == "" || mode == configurationCacheOff {
+		return nil
+	}
+	// Both --configuration-cache and --no-configuration-cache take precedence over the input.
+	if strings.Contains(gradleOptions, "configuration-cache") {
+		return nil
+	}
+	return []string{"--configuration-cache", "--configuration-cache-problems=" + mode}
+}
+
+// collectConfigurationCache registers the project's configuration cache entries for caching,
+// with cache level `all` they are already included with the project's .gradle dir.
+func collectConfigurationCache(buildRootAbs string, cacheLevel utilscache.Level) error {
+	if cacheLevel != utilscache.LevelDeps {
+		return nil
+	}
+
+	configurationCacheDir := filepath.Join(buildRootAbs, ".gradle", "configuration-cache")
+	if exist, err := pathutil.IsDirExists(configurationCacheDir); err != nil {
+		return err
+	} else if !exist {
+		return nil
+	}
+
+	gradleCache := utilscache.New()
+	gradleCache.IncludePath(configurationCacheDir)
+	return gradleCache.Commit()
+}
+
+func processConfigurationCacheResult(gradleOutput string) error {
+	result := parseConfigurationCacheResult(gradleOutput)
+	if result == nil {
+		log.Printf("Configuration cache was not used")
+		return nil
+	}
+
+	if result.Reused {
+		log.Donef("Configuration cache reused")
+	} else {
+		log.Warnf("Configuration cache not reused: %s", result.Reason)
+	}
+
+	for key, value := range map[string]string{
+		configurationCacheReusedEnvKey:             strconv.FormatBool(result.Reused),
+		configurationCacheInvalidationReasonEnvKey: result.Reason,
+	} {
+		if err := exportEnvironmentWithEnvman(key, value); err != nil {
+			return fmt.Errorf("failed to export environment (%s): %w", key, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfigurationCacheResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   *configurationCacheResult
+	}{
+		{
+			name:   "reused",
+			output: "Reusing configuration cache.\n> Task :app:assembleDebug UP-TO-DATE\n\nBUILD SUCCESSFUL in 1s\nConfiguration cache entry reused.\n",
+			want:   &configurationCacheResult{Reused: true},
+		},
+		{
+			name:   "no entry",
+			output: "Calculating task graph as no cached configuration is available for tasks: assembleDebug\n\nBUILD SUCCESSFUL in 10s\nConfiguration cache entry stored.\n",
+			want:   &configurationCacheResult{Reason: "no cached configuration is available for tasks: assembleDebug"},
+		},
+		{
+			name:   "invalidated",
+			output: "Calculating task graph as configuration cache cannot be reused because file 'app/build.gradle' has changed.\n",
+			want:   &configurationCacheResult{Reason: "file 'app/build.gradle' has changed"},
+		},
+		{
+			name:   "not used",
+			output: "> Task :app:assembleDebug\n\nBUILD SUCCESSFUL in 10s\n",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, parseConfigurationCacheResult(tt.output))
+		})
+	}
+}
+
+func TestConfigurationCacheArgs(t *testing.T) {
+	require.Equal(t, []string{"--configuration-cache", "--configuration-cache-problems=warn"}, configurationCacheArgs("warn", "--stacktrace"))
+	require.Nil(t, configurationCacheArgs("fail", "--no-configuration-cache"))
+	require.Nil(t, configurationCacheArgs("off", ""))
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	coverageSummaryFileName = "coverage-summary.json"
+
+	coverageLineEnvKey        = "BITRISE_COVERAGE_LINE_PERCENT"
+	coverageBranchEnvKey      = "BITRISE_COVERAGE_BRANCH_PERCENT"
+	coverageInstructionEnvKey = "BITRISE_COVERAGE_INSTRUCTION_PERCENT"
+	coverageSummaryEnvKey     = "BITRISE_COVERAGE_SUMMARY_PATH"
+)
+
+// JaCoCo and Kover (which writes JaCoCo compatible XML) reports, like:
+// app/build/reports/jacoco/jacocoTestReport/jacocoTestReport.xml or lib/build/reports/kover/report.xml
+var coverageReportPatterns = filePatterns{
+	include: []string{"*reports/jacoco/*.xml", "*reports/kover/*.xml"},
+}
+
+type coverageCounter struct {
+	Covered int     `json:"covered"`
+	Missed  int     `json:"missed"`
+	Percent float64 `json:"percent"`
+}
+
+func (c *coverageCounter) add(covered, missed int) {
+	c.Covered += covered
+	c.Missed += missed
+	if total := c.Covered + c.Missed; total > 0 {
+		c.Percent = float64(c.Covered) * 100 / float64(total)
+	}
+}
+
+type coverage struct {
+	Line        coverageCounter `json:"line"`
+	Branch      coverageCounter `json:"branch"`
+	Instruction coverageCounter `json:"instruction"`
+}
+
+func (c *coverage) add(other coverage) {
+	c.Line.add(other.Line.Covered, other.Line.Missed)
+	c.Branch.add(other.Branch.Covered, other.Branch.Missed)
+	c.Instruction.add(other.Instruction.Covered, other.Instruction.Missed)
+}
+
+type coverageSummary struct {
+	Total   coverage            `json:"total"`
+	Modules map[string]coverage `json:"modules"`
+	Reports []string            `json:"reports"`
+}
+
+type jacocoReport struct {
+	XMLName  xml.Name `xml:"report"`
+	Counters []struct {
+		Type    string `xml:"type,attr"`
+		Missed  int    `xml:"missed,attr"`
+		Covered int    `xml:"covered,attr"`
+	} `xml:"counter"`
+}
+
+func parseCoverageReport(pth string) (coverage, error) {
+	content, err := os.ReadFile(pth)
+	if err != nil {
+		return coverage{}, err
+	}
+
+	var report jacocoReport
+	if err := xml.Unmarshal(content, &report); err != nil {
+		return coverage{}, fmt.Errorf("failed to parse coverage report (%s): %w", pth, err)
+	}
+
+	// Only the counters directly under <report> hold the report level totals,
+	// package, class and method level counters are nested deeper.
+	var cov coverage
+	for _, counter := range report.Counters {
+		switch counter.Type {
+		case "LINE":
+			cov.Line.add(counter.Covered, counter.Missed)
+		case "BRANCH":
+			cov.Branch.add(counter.Covered, counter.Missed)
+		case "INSTRUCTION":
+			cov.Instruction.add(counter.Covered, counter.Missed)
+		}
+	}
+
+	return cov, nil
+}
+
+// gradleModuleForPath returns the Gradle project path (like :feature:login) of the module
+// owning the given file, based on the build directory in the file's path relative to the build root.
+func gradleModuleForPath(buildRootDir, pth string) string {
+	relPath, err := filepath.Rel(buildRootDir, pth)
+	if err != nil {
+		return ":"
+	}
+
+	components := strings.Split(filepath.ToSlash(relPath), "/")
+	for i, component := range components {
+		if component == "build" {
+			return ":" + strings.Join(components[:i], ":")
+		}
+	}
+
+	return ":"
+}
+
+func collectCoverage(buildRootDir string) (coverageSummary, error) {
+	summary := coverageSummary{Modules: map[string]coverage{}}
+
+	reports, err := findArtifacts(buildRootDir, coverageReportPatterns)
+	if err != nil {
+		return summary, err
+	}
+
+	// The reports of the root project (like a merged Kover or JaCoCo report) aggregate the module reports,
+	// they are only counted if there is no module report.
+	var moduleReports, rootReports []string
+	for _, report := range reports {
+		if gradleModuleForPath(buildRootDir, report) == ":" {
+			rootReports = append(rootReports, report)
+		} else {
+			moduleReports = append(moduleReports, report)
+		}
+	}
+	if len(moduleReports) > 0 {
+		for _, report := range rootReports {
+			log.Printf("Skipping aggregated coverage report: %s", report)
+		}
+		reports = moduleReports
+	}
+
+	for _, report := range reports {
+		cov, err := parseCoverageReport(report)
+		if err != nil {
+			log.Warnf("%s", err)
+			continue
+		}
+
+		module := gradleModuleForPath(buildRootDir, report)
+		moduleCoverage := summary.Modules[module]
+		moduleCoverage.add(cov)
+		summary.Modules[module] = moduleCoverage
+		summary.Total.add(cov)
+		summary.Reports = append(summary.Reports, report)
+	}
+
+	return summary, nil
+}
+
+func readCoverageSummary(pth string) (coverageSummary, error) {
+	var summary coverageSummary
+
+	content, err := os.ReadFile(pth)
+	if err != nil {
+		return summary, err
+	}
+
+	if err := json.Unmarshal(content, &summary); err != nil {
+		return summary, fmt.Errorf("failed to parse coverage summary (%s): %w", pth, err)
+	}
+
+	return summary, nil
+}
+
+// checkCoverageGates returns an error if the total line coverage is below minPercent
+// or it dropped more than maxDrop percentage points compared to the baseline.
+func checkCoverageGates(summary coverageSummary, minPercent float64, baseline *coverageSummary, maxDrop float64) error {
+	current := summary.Total.Line.Percent
+
+	if minPercent > 0 && current < minPercent {
+		return fmt.Errorf("line coverage (%.2f%%) is below the minimum (%.2f%%)", current, minPercent)
+	}
+
+	if baseline != nil {
+		if drop := baseline.Total.Line.Percent - current; drop > maxDrop {
+			return fmt.Errorf("line coverage dropped by %.2f%% (%.2f%% -> %.2f%%), allowed drop is %.2f%%", drop, baseline.Total.Line.Percent, current, maxDrop)
+		}
+	}
+
+	return nil
+}
+
+func printCoverageSummary(summary coverageSummary) {
+	var modules []string
+	for module := range summary.Modules {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+
+	log.Printf("%-40s %10s %10s %12s", "Module", "Line", "Branch", "Instruction")
+	for _, module := range modules {
+		cov := summary.Modules[module]
+		log.Printf("%-40s %9.2f%% %9.2f%% %11.2f%%", module, cov.Line.Percent, cov.Branch.Percent, cov.Instruction.Percent)
+	}
+	log.Printf("%-40s %9.2f%% %9.2f%% %11.2f%%", "Total", summary.Total.Line.Percent, summary.Total.Branch.Percent, summary.Total.Instruction.Percent)
+}
+
+func exportCoverageSummary(summary coverageSummary, deployDir string) error {
+	content, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	summaryPth := filepath.Join(deployDir, coverageSummaryFileName)
+	if err := os.WriteFile(summaryPth, content, 0644); err != nil {
+		return fmt.Errorf("failed to write coverage summary: %w", err)
+	}
+
+	for key, value := range map[string]string{
+		coverageLineEnvKey:        fmt.Sprintf("%.2f", summary.Total.Line.Percent),
+		coverageBranchEnvKey:      fmt.Sprintf("%.2f", summary.Total.Branch.Percent),
+		coverageInstructionEnvKey: fmt.Sprintf("%.2f", summary.Total.Instruction.Percent),
+		coverageSummaryEnvKey:     summaryPth,
+	} {
+		if err := exportEnvironmentWithEnvman(key, value); err != nil {
+			return fmt.Errorf("failed to export environment (%s): %w", key, err)
+		}
+		log.Donef("The coverage is now available in the Environment Variable: $%s (value: %s)", key, value)
+	}
+
+	return nil
+}
+
+func processCoverage(configs Config, buildRootAbs string) error {
+	summary, err := collectCoverage(buildRootAbs)
+	if err != nil {
+		return fmt.Errorf("failed to collect coverage reports: %w", err)
+	}
+	if len(summary.Reports) == 0 {
+		log.Warnf("No JaCoCo or Kover XML report found")
+		return nil
+	}
+
+	printCoverageSummary(summary)
+	if err := exportCoverageSummary(summary, configs.DeployDir); err != nil {
+		return err
+	}
+
+	var baseline *coverageSummary
+	if configs.CoverageBaselinePath != "" {
+		baselineSummary, err := readCoverageSummary(configs.CoverageBaselinePath)
+		if err != nil {
+			log.Warnf("Failed to read coverage baseline, skipping the drop check: %s", err)
+		} else {
+			baseline = &baselineSummary
+		}
+	}
+
+	return checkCoverageGates(summary, configs.CoverageMinimumPercent, baseline, configs.CoverageMaxDropPercent)
+}
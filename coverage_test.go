@@ -0,0 +1,181 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const jacocoReportContent = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<!DOCTYPE report PUBLIC "-//JACOCO//DTD Report 1.1//EN" "report.dtd">
+<report name="app">
+  <sessioninfo id="session" start="1" dump="2"/>
+  <package name="io/bitrise/sample">
+    <class name="io/bitrise/sample/MainActivity" sourcefilename="MainActivity.kt">
+      <counter type="INSTRUCTION" missed="10" covered="10"/>
+      <counter type="LINE" missed="2" covered="2"/>
+    </class>
+    <counter type="INSTRUCTION" missed="10" covered="10"/>
+    <counter type="LINE" missed="2" covered="2"/>
+  </package>
+  <counter type="INSTRUCTION" missed="25" covered="75"/>
+  <counter type="BRANCH" missed="5" covered="5"/>
+  <counter type="LINE" missed="10" covered="30"/>
+  <counter type="METHOD" missed="1" covered="9"/>
+</report>
+`
+
+const koverReportContent = `<?xml version="1.0" ?>
+<report name="Kover report">
+  <counter type="INSTRUCTION" missed="0" covered="100"/>
+  <counter type="BRANCH" missed="5" covered="15"/>
+  <counter type="LINE" missed="20" covered="40"/>
+</report>
+`
+
+func TestCollectCoverage(t *testing.T) {
+	buildRootDir := t.TempDir()
+	for pth, content := range map[string]string{
+		"app/build/reports/jacoco/jacocoTestReport/jacocoTestReport.xml": jacocoReportContent,
+		"feature/login/build/reports/kover/report.xml":                   koverReportContent,
+		"app/build/reports/tests/index.html":                             "<html></html>",
+	} {
+		fullPth := filepath.Join(buildRootDir, pth)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPth), 0755))
+		require.NoError(t, os.WriteFile(fullPth, []byte(content), 0644))
+	}
+
+	summary, err := collectCoverage(buildRootDir)
+	require.NoError(t, err)
+	require.Len(t, summary.Reports, 2)
+
+	require.Equal(t, coverageCounter{Covered: 30, Missed: 10, Percent: 75}, summary.Modules[":app"].Line)
+	require.Equal(t, coverageCounter{Covered: 5, Missed: 5, Percent: 50}, summary.Modules[":app"].Branch)
+	require.Equal(t, coverageCounter{Covered: 75, Missed: 25, Percent: 75}, summary.Modules[":app"].Instruction)
+	require.Equal(t, coverageCounter{Covered: 40, Missed: 20, Percent: float64(40) * 100 / 60}, summary.Modules[":feature:login"].Line)
+
+	require.Equal(t, coverageCounter{Covered: 70, Missed: 30, Percent: 70}, summary.Total.Line)
+	require.Equal(t, coverageCounter{Covered: 20, Missed: 10, Percent: float64(20) * 100 / 30}, summary.Total.Branch)
+	require.Equal(t, coverageCounter{Covered: 175, Missed: 25, Percent: 87.5}, summary.Total.Instruction)
+}
+
+func TestCollectCoverageAggregatedReport(t *testing.T) {
+	buildRootDir := t.TempDir()
+	writeReport := func(pth, content string) {
+		fullPth := filepath.Join(buildRootDir, pth)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPth), 0755))
+		require.NoError(t, os.WriteFile(fullPth, []byte(content), 0644))
+	}
+
+	// Only the merged report of the root project
+	writeReport("build/reports/kover/report.xml", koverReportContent)
+	summary, err := collectCoverage(buildRootDir)
+	require.NoError(t, err)
+	require.Len(t, summary.Reports, 1)
+	require.Equal(t, coverageCounter{Covered: 40, Missed: 20, Percent: float64(40) * 100 / 60}, summary.Total.Line)
+
+	// The module reports are not counted twice
+	writeReport("app/build/reports/jacoco/jacocoTestReport/jacocoTestReport.xml", jacocoReportContent)
+	summary, err = collectCoverage(buildRootDir)
+	require.NoError(t, err)
+	require.Len(t, summary.Reports, 1)
+	require.NotContains(t, summary.Modules, ":")
+	require.Equal(t, coverageCounter{Covered: 30, Missed: 10, Percent: 75}, summary.Total.Line)
+}
+
+func TestGradleModuleForPath(t *testing.T) {
+	tests := []struct {
+		name string
+		pth  string
+		want string
+	}{
+		{
+			name: "root project",
+			pth:  "/project/build/reports/kover/report.xml",
+			want: ":",
+		},
+		{
+			name: "module",
+			pth:  "/project/app/build/reports/kover/report.xml",
+			want: ":app",
+		},
+		{
+			name: "nested module",
+			pth:  "/project/feature/login/build/test-results/test/TEST-a.xml",
+			want: ":feature:login",
+		},
+		{
+			name: "not in a build dir",
+			pth:  "/project/reports/report.xml",
+			want: ":",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, gradleModuleForPath("/project", tt.pth))
+		})
+	}
+}
+
+func TestCheckCoverageGates(t *testing.T) {
+	summaryWithLineCoverage := func(percent float64) coverageSummary {
+		return coverageSummary{Total: coverage{Line: coverageCounter{Percent: percent}}}
+	}
+	baseline := summaryWithLineCoverage(80)
+
+	tests := []struct {
+		name       string
+		current    float64
+		minPercent float64
+		baseline   *coverageSummary
+		maxDrop    float64
+		wantErr    bool
+	}{
+		{
+			name:    "no gates",
+			current: 10,
+		},
+		{
+			name:       "above minimum",
+			current:    80,
+			minPercent: 75,
+		},
+		{
+			name:       "below minimum",
+			current:    70,
+			minPercent: 75,
+			wantErr:    true,
+		},
+		{
+			name:     "improved compared to baseline",
+			current:  85,
+			baseline: &baseline,
+		},
+		{
+			name:     "dropped within the allowed range",
+			current:  79.5,
+			baseline: &baseline,
+			maxDrop:  1,
+		},
+		{
+			name:     "dropped compared to baseline",
+			current:  79.5,
+			baseline: &baseline,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCoverageGates(summaryWithLineCoverage(tt.current), tt.minPercent, tt.baseline, tt.maxDrop)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	criticalPathFileName = "critical-path.json"
+	criticalPathEnvKey   = "BITRISE_GRADLE_CRITICAL_PATH_REPORT_PATH"
+)
+
+type criticalPathStep struct {
+	Path     string  `json:"path"`
+	Module   string  `json:"module"`
+	Outcome  string  `json:"outcome"`
+	Duration float64 `json:"duration"`
+	// Wait is the time between the end of the blocking dependency (or the build start) and the task's start.
+	Wait float64 `json:"wait"`
+}
+
+type workerUtilisation struct {
+	Worker      int     `json:"worker"`
+	Busy        float64 `json:"busy"`
+	Utilisation float64 `json:"utilisation"`
+}
+
+type moduleImpact struct {
+	Module       string  `json:"module"`
+	CriticalPath float64 `json:"critical_path"`
+	Total        float64 `json:"total"`
+	Tasks        int     `json:"tasks"`
+}
+
+// criticalPathReport describes which tasks bound the wall-clock time of the build, all durations are in seconds.
+type criticalPathReport struct {
+	WallClock            float64             `json:"wall_clock"`
+	TotalTaskTime        float64             `json:"total_task_time"`
+	AverageParallelism   float64             `json:"average_parallelism"`
+	CriticalPathDuration float64             `json:"critical_path_duration"`
+	CriticalPath         []criticalPathStep  `json:"critical_path"`
+	Workers              []workerUtilisation `json:"workers"`
+	Modules              []moduleImpact      `json:"modules"`
+}
+
+func taskModule(taskPath string) string {
+	i := strings.LastIndex(taskPath, ":")
+	if i <= 0 {
+		return ":"
+	}
+	return taskPath[:i]
+}
+
+func millisToSeconds(ms int64) float64 {
+	return float64(ms) / 1000
+}
+
+// criticalPath walks back from the last finishing task, following the dependency which finished last
+// before each task started: that is the dependency which actually held the task back.
+func criticalPath(events []taskEvent, buildStart int64) []criticalPathStep {
+	byPath := map[string]taskEvent{}
+	var last *taskEvent
+	for i, event := range events {
+		byPath[event.Path] = event
+		if last == nil || event.End > last.End {
+			last = &events[i]
+		}
+	}
+	if last == nil {
+		return nil
+	}
+
+	var steps []criticalPathStep
+	visited := map[string]bool{}
+	current := *last
+	for {
+		visited[current.Path] = true
+
+		var blocker *taskEvent
+		for _, dependency := range current.Dependencies {
+			dep, ok := byPath[dependency]
+			if !ok || visited[dep.Path] || dep.End > current.Start {
+				continue
+			}
+			if blocker == nil || dep.End > blocker.End {
+				d := dep
+				blocker = &d
+			}
+		}
+
+		blockedUntil := buildStart
+		if blocker != nil {
+			blockedUntil = blocker.End
+		}
+
+		steps = append(steps, criticalPathStep{
+			Path:     current.Path,
+			Module:   taskModule(current.Path),
+			Outcome:  current.Outcome,
+			Duration: millisToSeconds(current.End - current.Start),
+			Wait:     millisToSeconds(current.Start - blockedUntil),
+		})
+
+		if blocker == nil {
+			break
+		}
+		current = *blocker
+	}
+
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+
+	return steps
+}
+
+func analyzeCriticalPath(events []taskEvent) criticalPathReport {
+	var report criticalPathReport
+	if len(events) == 0 {
+		return report
+	}
+
+	buildStart, buildEnd := events[0].Start, events[0].End
+	for _, event := range events {
+		if event.Start < buildStart {
+			buildStart = event.Start
+		}
+		if event.End > buildEnd {
+			buildEnd = event.End
+		}
+	}
+	report.WallClock = millisToSeconds(buildEnd - buildStart)
+
+	report.CriticalPath = criticalPath(events, buildStart)
+	criticalByModule := map[string]float64{}
+	for _, step := range report.CriticalPath {
+		report.CriticalPathDuration += step.Duration
+		criticalByModule[step.Module] += step.Duration
+	}
+
+	var busy []float64
+	modules := map[string]*moduleImpact{}
+	for i, worker := range assignWorkers(events) {
+		duration := millisToSeconds(events[i].End - events[i].Start)
+		report.TotalTaskTime += duration
+
+		for len(busy) <= worker {
+			busy = append(busy, 0)
+		}
+		busy[worker] += duration
+
+		module := taskModule(events[i].Path)
+		if modules[module] == nil {
+			modules[module] = &moduleImpact{Module: module, CriticalPath: criticalByModule[module]}
+		}
+		modules[module].Total += duration
+		modules[module].Tasks++
+	}
+
+	if report.WallClock > 0 {
+		report.AverageParallelism = report.TotalTaskTime / report.WallClock
+	}
+
+	for worker, busyTime := range busy {
+		utilisation := workerUtilisation{Worker: worker + 1, Busy: busyTime}
+		if report.WallClock > 0 {
+			utilisation.Utilisation = busyTime / report.WallClock
+		}
+		report.Workers = append(report.Workers, utilisation)
+	}
+
+	for _, module := range modules {
+		report.Modules = append(report.Modules, *module)
+	}
+	sort.Slice(report.Modules, func(i, j int) bool {
+		a, b := report.Modules[i], report.Modules[j]
+		if a.CriticalPath != b.CriticalPath {
+			return a.CriticalPath > b.CriticalPath
+		}
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Module < b.Module
+	})
+
+	return report
+}
+
+func printCriticalPathReport(report criticalPathReport) {
+	log.Printf("Wall-clock time: %.1fs, total task time: %.1fs, average parallelism: %.2f",
+		report.WallClock, report.TotalTaskTime, report.AverageParallelism)
+
+	fmt.Println()
+	log.Printf("Critical path (%.1fs of task time):", report.CriticalPathDuration)
+	for _, step := range report.CriticalPath {
+		log.Printf("  %-60s %-12s %7.1fs (waited %.1fs)", step.Path, step.Outcome, step.Duration, step.Wait)
+	}
+
+	fmt.Println()
+	log.Printf("Worker utilisation:")
+	for _, worker := range report.Workers {
+		log.Printf("  worker %-3d %7.1fs busy (%.0f%%)", worker.Worker, worker.Busy, worker.Utilisation*100)
+	}
+
+	fmt.Println()
+	log.Printf("Modules to split or optimise first (by time on the critical path):")
+	for _, module := range report.Modules {
+		if module.CriticalPath == 0 {
+			continue
+		}
+		log.Printf("  %-40s %7.1fs on the critical path, %7.1fs in %d tasks", module.Module, module.CriticalPath, module.Total, module.Tasks)
+	}
+}
+
+func processCriticalPath(events []taskEvent, deployDir string) error {
+	if len(events) == 0 {
+		log.Warnf("No task events recorded, skipping the critical path analysis")
+		return nil
+	}
+
+	report := analyzeCriticalPath(events)
+	printCriticalPathReport(report)
+
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	reportPth := filepath.Join(deployDir, criticalPathFileName)
+	if err := os.WriteFile(reportPth, content, 0644); err != nil {
+		return fmt.Errorf("failed to write critical path report: %w", err)
+	}
+
+	if err := exportEnvironmentWithEnvman(criticalPathEnvKey, reportPth); err != nil {
+		return fmt.Errorf("failed to export environment (%s): %w", criticalPathEnvKey, err)
+	}
+	log.Donef("The critical path report is now available in the Environment Variable: $%s (value: %s)", criticalPathEnvKey, reportPth)
+
+	return nil
+}
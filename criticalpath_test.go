@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeCriticalPath(t *testing.T) {
+	// :lib:compile -> :app:compile -> :app:assemble is the critical path,
+	// :other:compile runs in parallel and finishes earlier.
+	events := []taskEvent{
+		{Path: ":lib:compile", Outcome: "EXECUTED", Start: 0, End: 4000},
+		{Path: ":other:compile", Outcome: "FROM-CACHE", Start: 0, End: 1000},
+		{Path: ":app:compile", Outcome: "EXECUTED", Start: 4500, End: 8000, Dependencies: []string{":lib:compile", ":other:compile"}},
+		{Path: ":app:assemble", Outcome: "UP-TO-DATE", Start: 8000, End: 10000, Dependencies: []string{":app:compile"}},
+	}
+
+	report := analyzeCriticalPath(events)
+
+	require.Equal(t, 10.0, report.WallClock)
+	require.Equal(t, 10.5, report.TotalTaskTime)
+	require.Equal(t, 1.05, report.AverageParallelism)
+	require.Equal(t, 9.5, report.CriticalPathDuration)
+	require.Equal(t, []criticalPathStep{
+		{Path: ":lib:compile", Module: ":lib", Outcome: "EXECUTED", Duration: 4},
+		{Path: ":app:compile", Module: ":app", Outcome: "EXECUTED", Duration: 3.5, Wait: 0.5},
+		{Path: ":app:assemble", Module: ":app", Outcome: "UP-TO-DATE", Duration: 2},
+	}, report.CriticalPath)
+	require.Equal(t, []workerUtilisation{
+		{Worker: 1, Busy: 9.5, Utilisation: 0.95},
+		{Worker: 2, Busy: 1, Utilisation: 0.1},
+	}, report.Workers)
+	require.Equal(t, []moduleImpact{
+		{Module: ":app", CriticalPath: 5.5, Total: 5.5, Tasks: 2},
+		{Module: ":lib", CriticalPath: 4, Total: 4, Tasks: 1},
+		{Module: ":other", CriticalPath: 0, Total: 1, Tasks: 1},
+	}, report.Modules)
+}
+
+func TestCriticalPathWithoutDependencies(t *testing.T) {
+	events := []taskEvent{
+		{Path: ":a", Start: 1000, End: 2000},
+		{Path: ":b", Start: 1000, End: 3000},
+	}
+
+	require.Equal(t, []criticalPathStep{
+		{Path: ":b", Module: ":", Duration: 2},
+	}, criticalPath(events, 1000))
+}
+
+func TestTaskModule(t *testing.T) {
+	require.Equal(t, ":", taskModule(":assemble"))
+	require.Equal(t, ":", taskModule("assemble"))
+	require.Equal(t, ":app", taskModule(":app:assemble"))
+	require.Equal(t, ":feature:login", taskModule(":feature:login:compileKotlin"))
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	deprecationsFileName      = "gradle-deprecations.json"
+	deprecationsReportEnvKey  = "BITRISE_GRADLE_DEPRECATIONS_REPORT_PATH"
+	deprecationCountEnvKey    = "BITRISE_GRADLE_DEPRECATION_COUNT"
+	deprecatedFeaturesSummary = "Deprecated Gradle features were used in this build"
+)
+
+var (
+	// Like: The Project.getConvention() method has been deprecated. This is scheduled to be removed in Gradle 9.0. ...
+	// or the Android Gradle Plugin's: WARNING: The option setting 'android.enableJetifier=true' is deprecated.
+	deprecationRegexp = regexp.MustCompile(`(?i)(has|have) been deprecated|scheduled to be removed in Gradle|^WARNING:.*deprecated`)
+	// Compiler warnings (Kotlin, javac) about deprecated APIs are not Gradle deprecations.
+	compilerWarningRegexp = regexp.MustCompile(`^(w|e|warning|Note): `)
+	// Like: Build file '/bitrise/src/app/build.gradle': line 12
+	buildFileLocationRegexp = regexp.MustCompile(`^(?:Build file|Script|Settings file|Initialization script) '(.+)': line (\d+)`)
+	// Like: at build_5cw2jz$_run_closure1.doCall(/bitrise/src/app/build.gradle:12)
+	stackFrameRegexp = regexp.MustCompile(`^\s+at ([\w.$<>]+)\((.*)\)`)
+	// Like: /bitrise/src/app/build.gradle.kts:12
+	buildScriptFrameRegexp = regexp.MustCompile(`^(.+\.gradle(?:\.kts)?):(\d+)$`)
+)
+
+type deprecationWarning struct {
+	Message string `json:"message"`
+	// Source is the build script location (path:line) or the package of the plugin which triggered the warning.
+	Source string `json:"source,omitempty"`
+	Count  int    `json:"count"`
+}
+
+func isGradleInternalFrame(class string) bool {
+	for _, prefix := range []string{"org.gradle.", "java.", "javax.", "jdk.", "sun.", "groovy.", "org.codehaus.groovy.", "kotlin.", "worker.org.gradle."} {
+		if strings.HasPrefix(class, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// deprecationSource returns the build script location or the plugin package of a stack frame line,
+// or an empty string if the frame belongs to Gradle itself.
+func deprecationSource(frameLine string) string {
+	match := stackFrameRegexp.FindStringSubmatch(frameLine)
+	if match == nil {
+		return ""
+	}
+
+	method, location := match[1], match[2]
+	if scriptMatch := buildScriptFrameRegexp.FindStringSubmatch(location); scriptMatch != nil {
+		return scriptMatch[1] + ":" + scriptMatch[2]
+	}
+
+	if isGradleInternalFrame(method) {
+		return ""
+	}
+
+	// Drop the method and class name, like: com.android.build.gradle.internal.plugins.BasePlugin.apply
+	components := strings.Split(method, ".")
+	if len(components) > 2 {
+		return strings.Join(components[:len(components)-2], ".")
+	}
+	return method
+}
+
+// parseDeprecationWarnings collects the deduplicated deprecation warnings from the Gradle output,
+// along with whether Gradle reported the use of deprecated features (as it does with `--warning-mode summary`).
+func parseDeprecationWarnings(output string) ([]deprecationWarning, bool) {
+	var warnings []*deprecationWarning
+	byKey := map[string]*deprecationWarning{}
+	summaryFound := false
+
+	var current *deprecationWarning
+	pendingLocation := ""
+	finishCurrent := func() {
+		if current == nil {
+			return
+		}
+
+		key := current.Message + "\n" + current.Source
+		if existing, ok := byKey[key]; ok {
+			existing.Count++
+		} else {
+			current.Count = 1
+			byKey[key] = current
+			warnings = append(warnings, current)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		if current != nil {
+			if stackFrameRegexp.MatchString(line) {
+				if current.Source == "" {
+					current.Source = deprecationSource(line)
+				}
+				continue
+			}
+			if strings.HasPrefix(strings.TrimSpace(line), "(Run with --stacktrace") {
+				continue
+			}
+			finishCurrent()
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, deprecatedFeaturesSummary) {
+			summaryFound = true
+			continue
+		}
+
+		if match := buildFileLocationRegexp.FindStringSubmatch(trimmed); match != nil {
+			pendingLocation = match[1] + ":" + match[2]
+			continue
+		}
+
+		if compilerWarningRegexp.MatchString(trimmed) || !deprecationRegexp.MatchString(trimmed) {
+			pendingLocation = ""
+			continue
+		}
+
+		current = &deprecationWarning{Message: trimmed, Source: pendingLocation}
+		pendingLocation = ""
+	}
+	finishCurrent()
+
+	result := make([]deprecationWarning, 0, len(warnings))
+	for _, warning := range warnings {
+		result = append(result, *warning)
+	}
+
+	return result, summaryFound || len(result) > 0
+}
+
+func warningModeArgs(warningMode, gradleOptions string) []string {
+	if warningMode == "" || strings.Contains(gradleOptions, "--warning-mode") {
+		return nil
+	}
+	return []string{"--warning-mode=" + warningMode}
+}
+
+func processDeprecationWarnings(gradleOutput, deployDir string) error {
+	warnings, deprecatedFeaturesUsed := parseDeprecationWarnings(gradleOutput)
+	if !deprecatedFeaturesUsed {
+		log.Printf("No deprecation warning found")
+		return nil
+	}
+
+	if len(warnings) == 0 {
+		log.Warnf("Deprecated Gradle features were used in this build, set warning_mode to `all` to list them")
+	}
+
+	sorted := make([]deprecationWarning, len(warnings))
+	copy(sorted, warnings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Source < sorted[j].Source
+	})
+	for _, warning := range sorted {
+		source := warning.Source
+		if source == "" {
+			source = "unknown source"
+		}
+		log.Warnf("%s (%s, %dx)", warning.Message, source, warning.Count)
+	}
+
+	content, err := json.MarshalIndent(warnings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	reportPth := filepath.Join(deployDir, deprecationsFileName)
+	if err := os.WriteFile(reportPth, content, 0644); err != nil {
+		return fmt.Errorf("failed to write deprecation report: %w", err)
+	}
+
+	for key, value := range map[string]string{
+		deprecationsReportEnvKey: reportPth,
+		deprecationCountEnvKey:   strconv.Itoa(len(warnings)),
+	} {
+		if err := exportEnvironmentWithEnvman(key, value); err != nil {
+			return fmt.Errorf("failed to export environment (%s): %w", key, err)
+		}
+	}
+	log.Donef("The deprecation report is now available in the Environment Variable: $%s (value: %s)", deprecationsReportEnvKey, reportPth)
+
+	return nil
+}
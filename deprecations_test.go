@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const warningModeAllOutput = `> Configure project :app
+Build file '/bitrise/src/app/build.gradle': line 12
+The Project.getConvention() method has been deprecated. This is scheduled to be removed in Gradle 9.0. Consult the upgrading guide for further information: https://docs.gradle.org/8.2/userguide/upgrading_version_8.html#deprecated_access_to_conventions
+        at build_5cw2jz$_run_closure1.doCall(/bitrise/src/app/build.gradle:12)
+        (Run with --stacktrace to get the full stack trace of this deprecation warning.)
+The org.gradle.api.plugins.JavaPluginConvention type has been deprecated. This is scheduled to be removed in Gradle 9.0.
+        at org.gradle.api.internal.plugins.DefaultConvention.getPlugin(DefaultConvention.java:101)
+        at com.google.gms.googleservices.GoogleServicesPlugin.apply(GoogleServicesPlugin.kt:44)
+        at org.gradle.api.internal.plugins.ImperativeOnlyPluginTarget.applyImperative(ImperativeOnlyPluginTarget.java:43)
+
+> Configure project :lib
+The org.gradle.api.plugins.JavaPluginConvention type has been deprecated. This is scheduled to be removed in Gradle 9.0.
+        at org.gradle.api.internal.plugins.DefaultConvention.getPlugin(DefaultConvention.java:101)
+        at com.google.gms.googleservices.GoogleServicesPlugin.apply(GoogleServicesPlugin.kt:44)
+WARNING: The option setting 'android.enableJetifier=true' is deprecated.
+
+> Task :app:compileDebugKotlin
+w: file:///bitrise/src/app/src/main/java/Main.kt:10:5 'getter for foo: String' is deprecated. Deprecated in Java
+
+Deprecated Gradle features were used in this build, making it incompatible with Gradle 9.0.
+
+BUILD SUCCESSFUL in 10s
+`
+
+func TestParseDeprecationWarnings(t *testing.T) {
+	tests := []struct {
+		name         string
+		output       string
+		want         []deprecationWarning
+		wantDetected bool
+	}{
+		{
+			name:   "warning mode all",
+			output: warningModeAllOutput,
+			want: []deprecationWarning{
+				{
+					Message: "The Project.getConvention() method has been deprecated. This is scheduled to be removed in Gradle 9.0. Consult the upgrading guide for further information: https://docs.gradle.org/8.2/userguide/upgrading_version_8.html#deprecated_access_to_conventions",
+					Source:  "/bitrise/src/app/build.gradle:12",
+					Count:   1,
+				},
+				{
+					Message: "The org.gradle.api.plugins.JavaPluginConvention type has been deprecated. This is scheduled to be removed in Gradle 9.0.",
+					Source:  "com.google.gms.googleservices",
+					Count:   2,
+				},
+				{
+					Message: "WARNING: The option setting 'android.enableJetifier=true' is deprecated.",
+					Count:   1,
+				},
+			},
+			wantDetected: true,
+		},
+		{
+			name:         "warning mode summary",
+			output:       "BUILD SUCCESSFUL in 3s\nDeprecated Gradle features were used in this build, making it incompatible with Gradle 9.0.\n",
+			want:         []deprecationWarning{},
+			wantDetected: true,
+		},
+		{
+			name:         "no deprecations",
+			output:       "BUILD SUCCESSFUL in 3s\n1 actionable task: 1 executed\n",
+			want:         []deprecationWarning{},
+			wantDetected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, detected := parseDeprecationWarnings(tt.output)
+			require.Equal(t, tt.wantDetected, detected)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWarningModeArgs(t *testing.T) {
+	require.Equal(t, []string{"--warning-mode=all"}, warningModeArgs("all", "--stacktrace"))
+	require.Nil(t, warningModeArgs("all", "--stacktrace --warning-mode fail"))
+	require.Nil(t, warningModeArgs("", ""))
+}
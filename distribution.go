@@ -0,0 +1,282 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	gradleLauncherWrapper      = "wrapper"
+	gradleLauncherAuto         = "auto"
+	gradleLauncherDistribution = "distribution"
+
+	// The distributions are unpacked next to the ones of the wrapper (like ~/.gradle/wrapper/dists/gradle-8.7-bin),
+	// so the cache collection and pruning handle them the same way.
+	distributionInstallDirName = "bitrise-gradle-runner"
+)
+
+// useGradleDistribution decides if the build runs with a provisioned Gradle distribution instead of gradlew:
+// in auto mode it does if the project has no gradlew.
+func useGradleDistribution(launcher, gradlewPath string) bool {
+	switch launcher {
+	case gradleLauncherDistribution:
+		return true
+	case gradleLauncherAuto:
+		_, err := os.Stat(gradlewPath)
+		return errors.Is(err, fs.ErrNotExist)
+	default:
+		return false
+	}
+}
+
+// distributionVersion returns the Gradle version to provision: the gradle_version input,
+// or the version of the project's gradle-wrapper.properties, which is often committed without the rest of the wrapper.
+func distributionVersion(input, gradlewPath string) (string, error) {
+	if input != "" {
+		return input, nil
+	}
+
+	version, err := gradleWrapperVersion(gradlewPath)
+	if err != nil {
+		return "", fmt.Errorf("gradle_version is not set and %w", err)
+	}
+	return version, nil
+}
+
+func distributionInstallDir(gradleUserHome, version string) string {
+	return filepath.Join(gradleUserHome, "wrapper", "dists", "gradle-"+version+"-bin", distributionInstallDirName)
+}
+
+func distributionGradlePath(dir, version string) string {
+	return filepath.Join(dir, "gradle-"+version, "bin", "gradle")
+}
+
+func downloadFile(url, pth string) error {
+	client := http.Client{Timeout: 10 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warnf("Failed to close response body: %s", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	f, err := os.Create(pth)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func unzipFile(f *zip.File, pth string) error {
+	mode := f.Mode().Perm()
+	if mode == 0 {
+		mode = 0644
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			log.Warnf("Failed to close %s: %s", f.Name, err)
+		}
+	}()
+
+	w, err := os.OpenFile(pth, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// unzip extracts the archive into dir, refusing entries which would be written outside of it.
+func unzip(zipPth, dir string) error {
+	r, err := zip.OpenReader(zipPth)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			log.Warnf("Failed to close %s: %s", zipPth, err)
+		}
+	}()
+
+	for _, f := range r.File {
+		pth := filepath.Join(dir, f.Name)
+		if !strings.HasPrefix(pth, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid entry in %s: %s", zipPth, f.Name)
+		}
+
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(pth, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(pth), 0755); err != nil {
+			return err
+		}
+		if err := unzipFile(f, pth); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// installDistribution unpacks the distribution into installDir, through a temporary directory,
+// so an interrupted unpacking is not mistaken for an installed distribution next time.
+func installDistribution(zipPth, installDir, version string) (string, error) {
+	tmpDir := installDir + ".part"
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			log.Warnf("Failed to remove %s: %s", tmpDir, err)
+		}
+	}()
+
+	if err := unzip(zipPth, tmpDir); err != nil {
+		return "", fmt.Errorf("failed to unpack %s: %w", zipPth, err)
+	}
+
+	if _, err := os.Stat(distributionGradlePath(tmpDir, version)); err != nil {
+		return "", fmt.Errorf("%s is not a Gradle %s distribution, gradle-%s/bin/gradle is missing", zipPth, version, version)
+	}
+	if err := os.Chmod(distributionGradlePath(tmpDir, version), 0755); err != nil {
+		return "", err
+	}
+
+	if err := os.RemoveAll(installDir); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpDir, installDir); err != nil {
+		return "", err
+	}
+
+	return distributionGradlePath(installDir, version), nil
+}
+
+// localDistribution looks for the distribution in the distributions directory:
+// an unpacked gradle-<version> directory, or a gradle-<version>-bin.zip or gradle-<version>-all.zip archive.
+func localDistribution(distributionsDir, version string) (gradlePath, zipPth string) {
+	if pth := distributionGradlePath(distributionsDir, version); isFile(pth) {
+		return pth, ""
+	}
+
+	for _, kind := range []string{"bin", "all"} {
+		if pth := filepath.Join(distributionsDir, fmt.Sprintf("gradle-%s-%s.zip", version, kind)); isFile(pth) {
+			return "", pth
+		}
+	}
+	return "", ""
+}
+
+func isFile(pth string) bool {
+	info, err := os.Stat(pth)
+	return err == nil && !info.IsDir()
+}
+
+// downloadDistribution downloads the bin distribution from the mirror into dir,
+// and verifies it against the checksum published next to it, if there is one.
+func downloadDistribution(mirrorURL, version, dir string) (string, error) {
+	name := fmt.Sprintf("gradle-%s-bin.zip", version)
+	url := strings.TrimSuffix(mirrorURL, "/") + "/" + name
+	zipPth := filepath.Join(dir, name)
+
+	log.Printf("Downloading %s", url)
+	if err := downloadFile(url, zipPth); err != nil {
+		return "", err
+	}
+
+	checksum, err := fetchChecksum(url + ".sha256")
+	if err != nil {
+		log.Warnf("The checksum of the distribution is not verified: %s", err)
+		return zipPth, nil
+	}
+
+	actual, err := fileSHA256(zipPth)
+	if err != nil {
+		return "", err
+	}
+	if actual != checksum {
+		return "", fmt.Errorf("%s (SHA-256: %s) doesn't match the published checksum (SHA-256: %s)", url, actual, checksum)
+	}
+
+	return zipPth, nil
+}
+
+// provisionGradleDistribution returns the path of the bin/gradle script of the Gradle version, from (in this order)
+// an earlier installation in the Gradle user home, the local distributions directory or the mirror.
+func provisionGradleDistribution(version, distributionsDir, mirrorURL, gradleUserHome string) (string, error) {
+	installDir := distributionInstallDir(gradleUserHome, version)
+	if gradlePath := distributionGradlePath(installDir, version); isFile(gradlePath) {
+		log.Printf("Using the installed distribution: %s", installDir)
+		return gradlePath, nil
+	}
+
+	var zipPth string
+	if distributionsDir != "" {
+		var gradlePath string
+		gradlePath, zipPth = localDistribution(distributionsDir, version)
+		if gradlePath != "" {
+			log.Printf("Using the unpacked distribution: %s", filepath.Dir(filepath.Dir(gradlePath)))
+			return gradlePath, nil
+		}
+	}
+
+	if zipPth == "" {
+		if mirrorURL == "" {
+			return "", fmt.Errorf("the Gradle %s distribution is not found in the distributions directory (%s) and no mirror URL is set", version, distributionsDir)
+		}
+
+		tmpDir, err := os.MkdirTemp("", "gradle-distribution")
+		if err != nil {
+			return "", err
+		}
+		defer func() {
+			if err := os.RemoveAll(tmpDir); err != nil {
+				log.Warnf("Failed to remove %s: %s", tmpDir, err)
+			}
+		}()
+
+		zipPth, err = downloadDistribution(mirrorURL, version, tmpDir)
+		if err != nil {
+			return "", fmt.Errorf("failed to download Gradle %s: %w", version, err)
+		}
+	}
+
+	log.Printf("Unpacking %s to %s", filepath.Base(zipPth), installDir)
+	return installDistribution(zipPth, installDir, version)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createDistributionZip(t *testing.T, pth string, entries map[string]string) []byte {
+	f, err := os.Create(pth)
+	require.NoError(t, err)
+
+	w := zip.NewWriter(f)
+	for name, content := range entries {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		header.SetMode(0755)
+		entry, err := w.CreateHeader(header)
+		require.NoError(t, err)
+		_, err = entry.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	content, err := os.ReadFile(pth)
+	require.NoError(t, err)
+	return content
+}
+
+func TestUseGradleDistribution(t *testing.T) {
+	dir := t.TempDir()
+	gradlewPath := filepath.Join(dir, "gradlew")
+	missingGradlewPath := filepath.Join(dir, "missing", "gradlew")
+	require.NoError(t, os.WriteFile(gradlewPath, []byte("#!/bin/sh\n"), 0755))
+
+	require.False(t, useGradleDistribution(gradleLauncherWrapper, missingGradlewPath))
+	require.True(t, useGradleDistribution(gradleLauncherDistribution, gradlewPath))
+	require.False(t, useGradleDistribution(gradleLauncherAuto, gradlewPath))
+	require.True(t, useGradleDistribution(gradleLauncherAuto, missingGradlewPath))
+}
+
+func TestDistributionVersion(t *testing.T) {
+	dir := t.TempDir()
+	gradlewPath := filepath.Join(dir, "gradlew")
+
+	_, err := distributionVersion("", gradlewPath)
+	require.Error(t, err)
+
+	version, err := distributionVersion("8.7", gradlewPath)
+	require.NoError(t, err)
+	require.Equal(t, "8.7", version)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "gradle", "wrapper"), 0755))
+	require.NoError(t, os.WriteFile(gradleWrapperPropertiesPath(gradlewPath), []byte("distributionUrl=https\\://services.gradle.org/distributions/gradle-8.2.1-all.zip\n"), 0644))
+	version, err = distributionVersion("", gradlewPath)
+	require.NoError(t, err)
+	require.Equal(t, "8.2.1", version)
+}
+
+func TestProvisionGradleDistributionFromLocalDir(t *testing.T) {
+	gradleUserHome := t.TempDir()
+	distributionsDir := t.TempDir()
+	createDistributionZip(t, filepath.Join(distributionsDir, "gradle-8.7-all.zip"), map[string]string{
+		"gradle-8.7/bin/gradle":         "#!/bin/sh\n",
+		"gradle-8.7/lib/gradle-8.7.jar": "jar",
+	})
+
+	gradlePath, err := provisionGradleDistribution("8.7", distributionsDir, "", gradleUserHome)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(gradleUserHome, "wrapper", "dists", "gradle-8.7-bin", distributionInstallDirName, "gradle-8.7", "bin", "gradle"), gradlePath)
+
+	info, err := os.Stat(gradlePath)
+	require.NoError(t, err)
+	require.NotZero(t, info.Mode().Perm()&0100)
+	require.FileExists(t, filepath.Join(filepath.Dir(filepath.Dir(gradlePath)), "lib", "gradle-8.7.jar"))
+
+	// The installed distribution is used next time.
+	require.NoError(t, os.Remove(filepath.Join(distributionsDir, "gradle-8.7-all.zip")))
+	cachedPath, err := provisionGradleDistribution("8.7", distributionsDir, "", gradleUserHome)
+	require.NoError(t, err)
+	require.Equal(t, gradlePath, cachedPath)
+
+	_, err = provisionGradleDistribution("8.8", distributionsDir, "", gradleUserHome)
+	require.Error(t, err)
+}
+
+func TestProvisionGradleDistributionUnpacked(t *testing.T) {
+	distributionsDir := t.TempDir()
+	unpackedPath := filepath.Join(distributionsDir, "gradle-8.7", "bin", "gradle")
+	require.NoError(t, os.MkdirAll(filepath.Dir(unpackedPath), 0755))
+	require.NoError(t, os.WriteFile(unpackedPath, []byte("#!/bin/sh\n"), 0755))
+
+	gradlePath, err := provisionGradleDistribution("8.7", distributionsDir, "", t.TempDir())
+	require.NoError(t, err)
+	require.Equal(t, unpackedPath, gradlePath)
+}
+
+func TestProvisionGradleDistributionFromMirror(t *testing.T) {
+	zipContent := createDistributionZip(t, filepath.Join(t.TempDir(), "gradle.zip"), map[string]string{
+		"gradle-8.7/bin/gradle": "#!/bin/sh\n",
+	})
+	checksum := fmt.Sprintf("%x", sha256.Sum256(zipContent))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/distributions/gradle-8.7-bin.zip", "/distributions/gradle-8.8-bin.zip":
+			_, _ = w.Write(zipContent)
+		case "/distributions/gradle-8.7-bin.zip.sha256":
+			_, _ = w.Write([]byte(checksum))
+		case "/distributions/gradle-8.8-bin.zip.sha256":
+			_, _ = w.Write([]byte("0000000000000000000000000000000000000000000000000000000000000000"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	gradleUserHome := t.TempDir()
+	gradlePath, err := provisionGradleDistribution("8.7", "", server.URL+"/distributions/", gradleUserHome)
+	require.NoError(t, err)
+	require.FileExists(t, gradlePath)
+
+	_, err = provisionGradleDistribution("8.8", "", server.URL+"/distributions", gradleUserHome)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "doesn't match the published checksum")
+	require.NoDirExists(t, distributionInstallDir(gradleUserHome, "8.8"))
+
+	_, err = provisionGradleDistribution("8.9", "", server.URL+"/distributions", gradleUserHome)
+	require.Error(t, err)
+}
+
+func TestInstallDistributionRejectsInvalidArchives(t *testing.T) {
+	dir := t.TempDir()
+
+	zipPth := filepath.Join(dir, "escape.zip")
+	createDistributionZip(t, zipPth, map[string]string{"../escape": "content"})
+	_, err := installDistribution(zipPth, filepath.Join(dir, "install"), "8.7")
+	require.Error(t, err)
+	require.NoFileExists(t, filepath.Join(dir, "escape"))
+
+	zipPth = filepath.Join(dir, "other.zip")
+	createDistributionZip(t, zipPth, map[string]string{"gradle-8.6/bin/gradle": "#!/bin/sh\n"})
+	_, err = installDistribution(zipPth, filepath.Join(dir, "install"), "8.7")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "gradle-8.7/bin/gradle is missing")
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/kballard/go-shellquote"
+)
+
+const (
+	flakyTestsEnvKey  = "BITRISE_FLAKY_TESTS"
+	failedTestsEnvKey = "BITRISE_FAILED_TESTS"
+)
+
+// Like: Execution failed for task ':app:testDebugUnitTest'.
+var failedTaskRegexp = regexp.MustCompile(`^Execution failed for task '([^']+)'\.`)
+
+// buildFailures returns the tasks failed because of failing tests, and whether the build also failed for another reason
+// (a task failed with a different error, or the build failed outside of the tasks, like a compilation or configuration error).
+func buildFailures(gradleOutput string) (failedTestTasks []string, otherFailure bool) {
+	lines := strings.Split(gradleOutput, "\n")
+	failures := 0
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "* What went wrong:" {
+			continue
+		}
+		failures++
+
+		match := failedTaskRegexp.FindStringSubmatch(strings.TrimSpace(lineAt(lines, i+1)))
+		if match == nil {
+			otherFailure = true
+			continue
+		}
+
+		testFailure := false
+		for _, detail := range lines[i+2:] {
+			detail = strings.TrimSpace(detail)
+			if detail == "" || strings.HasPrefix(detail, "* ") {
+				break
+			}
+			if strings.Contains(detail, "There were failing tests") {
+				testFailure = true
+			}
+		}
+		if testFailure {
+			failedTestTasks = append(failedTestTasks, match[1])
+		} else {
+			otherFailure = true
+		}
+	}
+
+	// The failure is not reported in the output, like when Gradle can't start.
+	if failures == 0 {
+		otherFailure = true
+	}
+	return failedTestTasks, otherFailure
+}
+
+func lineAt(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return ""
+}
+
+// remainingTasks composes the tasks of the original build, excluding the given test tasks,
+// to run the tasks which didn't run because the build stopped at the failing tests.
+func remainingTasks(tasks string, testTasks []string) (string, error) {
+	args, err := shellquote.Split(tasks)
+	if err != nil {
+		return "", err
+	}
+	for _, task := range testTasks {
+		args = append(args, "-x", task)
+	}
+	return shellquote.Join(args...), nil
+}
+
+func gradleTaskPath(module, task string) string {
+	if module == ":" {
+		return ":" + task
+	}
+	return module + ":" + task
+}
+
+// testFilter returns the `--tests` filter matching the given test case.
+// Parameterized test names (like `sum(int, int)[1]` or `sum[1]`) are reduced to the method name.
+func testFilter(tc testCase) string {
+	name := tc.Name
+	if i := strings.IndexAny(name, "(["); i >= 0 {
+		name = name[:i]
+	}
+	name = strings.TrimSpace(name)
+
+	if name == "" {
+		return tc.ClassName
+	}
+	return tc.ClassName + "." + name
+}
+
+func testKey(tc testCase) string {
+	return gradleTaskPath(tc.Module, tc.Task) + " " + testFilter(tc)
+}
+
+// testRetryTasks composes the Gradle tasks, which re-run only the given tests
+// in the test tasks they belong to, like: `:app:testDebugUnitTest --tests 'io.bitrise.MainTest.sum'`.
+func testRetryTasks(tests []testCase) string {
+	filtersByTask := map[string][]string{}
+	seen := map[string]bool{}
+	for _, tc := range tests {
+		taskPath := gradleTaskPath(tc.Module, tc.Task)
+		filter := testFilter(tc)
+		if seen[taskPath+" "+filter] {
+			continue
+		}
+		seen[taskPath+" "+filter] = true
+		filtersByTask[taskPath] = append(filtersByTask[taskPath], filter)
+	}
+
+	var taskPaths []string
+	for taskPath := range filtersByTask {
+		taskPaths = append(taskPaths, taskPath)
+	}
+	sort.Strings(taskPaths)
+
+	var args []string
+	for _, taskPath := range taskPaths {
+		args = append(args, taskPath)
+		for _, filter := range filtersByTask[taskPath] {
+			args = append(args, "--tests", filter)
+		}
+	}
+
+	return shellquote.Join(args...)
+}
+
+// classifyRetriedTests splits the originally failed tests into flaky ones (passed on retry)
+// and hard failures (failed again or were not executed by the retry).
+func classifyRetriedTests(failed, retried []testCase) (flaky []string, hardFailures []string) {
+	retriedPassed := map[string]bool{}
+	retriedFailed := map[string]bool{}
+	for _, tc := range retried {
+		if tc.Failed {
+			retriedFailed[testKey(tc)] = true
+		} else if !tc.Skipped {
+			retriedPassed[testKey(tc)] = true
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, tc := range failed {
+		key := testKey(tc)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if retriedPassed[key] && !retriedFailed[key] {
+			flaky = append(flaky, key)
+		} else {
+			hardFailures = append(hardFailures, key)
+		}
+	}
+
+	sort.Strings(flaky)
+	sort.Strings(hardFailures)
+
+	return flaky, hardFailures
+}
+
+func failedTests(tests []testCase) []testCase {
+	var failed []testCase
+	for _, tc := range tests {
+		if tc.Failed {
+			failed = append(failed, tc)
+		}
+	}
+	return failed
+}
+
+// retryFailedTests re-runs the tests failed since gradleStarted once, if they were the only failures of the build,
+// then runs the rest of the tasks (which might have not run because of the failing tests).
+// It returns an error if some of the tests failed again, or the build failed for another reason.
+func retryFailedTests(gradlewPath, tasks, gradleOutput string, configs Config, gradleArgs []string, buildRootAbs string, gradleStarted time.Time) error {
+	failedTestTasks, otherFailure := buildFailures(gradleOutput)
+	if otherFailure {
+		return fmt.Errorf("the build failed not only because of failing tests, the tests are not retried")
+	}
+
+	results, err := findTestResults(buildRootAbs, gradleStarted)
+	if err != nil {
+		return fmt.Errorf("failed to find test results: %w", err)
+	}
+
+	failed := failedTests(results)
+	if len(failed) == 0 {
+		return fmt.Errorf("no failed test found to retry")
+	}
+
+	retryTasks := testRetryTasks(failed)
+	log.Warnf("%d test(s) failed, retrying them once...", len(failed))
+
+	retryStarted := time.Now()
+	_, retryErr := runGradleTask(gradlewPath, retryTasks, configs.GradleOptions, gradleArgs, buildRootAbs, configs.DeployDir)
+
+	retried, err := findTestResults(buildRootAbs, retryStarted)
+	if err != nil {
+		return fmt.Errorf("failed to find test results of the retry: %w", err)
+	}
+
+	flaky, hardFailures := classifyRetriedTests(failed, retried)
+
+	fmt.Println()
+	if len(flaky) > 0 {
+		log.Warnf("Flaky tests (passed on retry):")
+		for _, test := range flaky {
+			log.Warnf("- %s", test)
+		}
+	}
+	if len(hardFailures) > 0 {
+		log.Errorf("Failed tests (failed on retry):")
+		for _, test := range hardFailures {
+			log.Errorf("- %s", test)
+		}
+	}
+
+	for key, tests := range map[string][]string{
+		flakyTestsEnvKey:  flaky,
+		failedTestsEnvKey: hardFailures,
+	} {
+		value := strings.Join(tests, "\n")
+		if err := exportEnvironmentWithEnvman(key, value); err != nil {
+			log.Warnf("Failed to export environment (%s): %s", key, err)
+		}
+	}
+
+	if len(hardFailures) > 0 {
+		return fmt.Errorf("%d test(s) failed on retry", len(hardFailures))
+	}
+	if retryErr != nil {
+		return fmt.Errorf("retrying the failed tests failed: %w", retryErr)
+	}
+
+	remaining, err := remainingTasks(tasks, failedTestTasks)
+	if err != nil {
+		return err
+	}
+	log.Infof("Running the remaining tasks...")
+	if _, err := runGradleTask(gradlewPath, remaining, configs.GradleOptions, gradleArgs, buildRootAbs, configs.DeployDir); err != nil {
+		return fmt.Errorf("running the remaining tasks failed: %w", err)
+	}
+
+	return nil
+}
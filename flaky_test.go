@@ -0,0 +1,142 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestRetryTasks(t *testing.T) {
+	tests := []struct {
+		name  string
+		tests []testCase
+		want  string
+	}{
+		{
+			name: "single test",
+			tests: []testCase{
+				{Module: ":app", Task: "testDebugUnitTest", ClassName: "io.bitrise.MainTest", Name: "sum()"},
+			},
+			want: ":app:testDebugUnitTest --tests io.bitrise.MainTest.sum",
+		},
+		{
+			name: "parameterized tests of the same method are retried once",
+			tests: []testCase{
+				{Module: ":app", Task: "test", ClassName: "io.bitrise.MainTest", Name: "sum(int, int)[1]"},
+				{Module: ":app", Task: "test", ClassName: "io.bitrise.MainTest", Name: "sum(int, int)[2]"},
+				{Module: ":app", Task: "test", ClassName: "io.bitrise.MainTest", Name: "divide[0]"},
+			},
+			want: ":app:test --tests io.bitrise.MainTest.sum --tests io.bitrise.MainTest.divide",
+		},
+		{
+			name: "multiple modules and the root project",
+			tests: []testCase{
+				{Module: ":feature:login", Task: "test", ClassName: "io.bitrise.LoginTest", Name: "login"},
+				{Module: ":", Task: "test", ClassName: "io.bitrise.RootTest", Name: "should work"},
+			},
+			want: ":feature:login:test --tests io.bitrise.LoginTest.login :test --tests 'io.bitrise.RootTest.should work'",
+		},
+		{
+			name: "class level failure",
+			tests: []testCase{
+				{Module: ":app", Task: "test", ClassName: "io.bitrise.MainTest", Name: "initializationError"},
+				{Module: ":app", Task: "test", ClassName: "io.bitrise.OtherTest", Name: ""},
+			},
+			want: ":app:test --tests io.bitrise.MainTest.initializationError --tests io.bitrise.OtherTest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, testRetryTasks(tt.tests))
+		})
+	}
+}
+
+func TestClassifyRetriedTests(t *testing.T) {
+	failed := []testCase{
+		{Module: ":app", Task: "test", ClassName: "io.bitrise.MainTest", Name: "flaky()", Failed: true},
+		{Module: ":app", Task: "test", ClassName: "io.bitrise.MainTest", Name: "broken()", Failed: true},
+		{Module: ":app", Task: "test", ClassName: "io.bitrise.MainTest", Name: "notRetried()", Failed: true},
+		{Module: ":lib", Task: "test", ClassName: "io.bitrise.LibTest", Name: "param[1]", Failed: true},
+	}
+	retried := []testCase{
+		{Module: ":app", Task: "test", ClassName: "io.bitrise.MainTest", Name: "flaky()"},
+		{Module: ":app", Task: "test", ClassName: "io.bitrise.MainTest", Name: "broken()", Failed: true},
+		{Module: ":lib", Task: "test", ClassName: "io.bitrise.LibTest", Name: "param[1]"},
+		{Module: ":lib", Task: "test", ClassName: "io.bitrise.LibTest", Name: "param[2]", Failed: true},
+	}
+
+	flaky, hardFailures := classifyRetriedTests(failed, retried)
+	require.Equal(t, []string{":app:test io.bitrise.MainTest.flaky"}, flaky)
+	require.Equal(t, []string{
+		":app:test io.bitrise.MainTest.broken",
+		":app:test io.bitrise.MainTest.notRetried",
+		":lib:test io.bitrise.LibTest.param",
+	}, hardFailures)
+}
+
+func TestBuildFailures(t *testing.T) {
+	testsOnly := `> Task :app:testDebugUnitTest FAILED
+
+FAILURE: Build completed with 2 failures.
+
+1: Task failed with an exception.
+-----------
+* What went wrong:
+Execution failed for task ':app:testDebugUnitTest'.
+> There were failing tests. See the report at: file:///project/app/build/reports/tests/testDebugUnitTest/index.html
+
+* Try:
+> Run with --scan to get full insights.
+==============================================================================
+
+2: Task failed with an exception.
+-----------
+* What went wrong:
+Execution failed for task ':lib:testDebugUnitTest'.
+> There were failing tests. See the report at: file:///project/lib/build/reports/tests/testDebugUnitTest/index.html
+
+BUILD FAILED in 12s
+`
+	tasks, other := buildFailures(testsOnly)
+	require.Equal(t, []string{":app:testDebugUnitTest", ":lib:testDebugUnitTest"}, tasks)
+	require.False(t, other)
+
+	compileError := `FAILURE: Build completed with 2 failures.
+
+1: Task failed with an exception.
+-----------
+* What went wrong:
+Execution failed for task ':app:testDebugUnitTest'.
+> There were failing tests. See the report at: file:///project/app/build/reports/tests/testDebugUnitTest/index.html
+
+2: Task failed with an exception.
+-----------
+* What went wrong:
+Execution failed for task ':app:compileReleaseKotlin'.
+> A failure occurred while executing org.jetbrains.kotlin.compilerRunner.GradleCompilerRunnerWithWorkers$GradleKotlinCompilerWorkAction
+   > Compilation error. See log for more details
+`
+	tasks, other = buildFailures(compileError)
+	require.Equal(t, []string{":app:testDebugUnitTest"}, tasks)
+	require.True(t, other)
+
+	configurationError := `FAILURE: Build failed with an exception.
+
+* What went wrong:
+A problem occurred evaluating project ':app'.
+> Could not find method implementation()
+`
+	_, other = buildFailures(configurationError)
+	require.True(t, other)
+
+	_, other = buildFailures("Error: Could not find or load main class org.gradle.wrapper.GradleWrapperMain")
+	require.True(t, other)
+}
+
+func TestRemainingTasks(t *testing.T) {
+	remaining, err := remainingTasks("testDebugUnitTest assembleDebug", []string{":app:testDebugUnitTest"})
+	require.NoError(t, err)
+	require.Equal(t, "testDebugUnitTest assembleDebug -x :app:testDebugUnitTest", remaining)
+}
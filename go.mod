@@ -3,7 +3,6 @@ module github.com/bitrise-steplib/steps-gradle-runner
 go 1.18
 
 require (
-	github.com/bitrise-io/go-android v1.0.3
 	github.com/bitrise-io/go-steputils v1.0.5
 	github.com/bitrise-io/go-utils v1.0.8
 	github.com/bitrise-io/go-utils/v2 v2.0.0-alpha.25
@@ -16,7 +15,6 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/hashicorp/go-cleanhttp v0.5.2 // indirect
 	github.com/hashicorp/go-retryablehttp v0.7.7 // indirect
-	github.com/hashicorp/go-version v1.6.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/kballard/go-shellquote"
+)
+
+const gradleUserHomeEnvKey = "GRADLE_USER_HOME"
+
+// gradleUserHomeOption returns the Gradle user home set by the Gradle options, if any: the -g or --gradle-user-home option
+// takes precedence over the gradle.user.home system property (-Dgradle.user.home), like in Gradle.
+func gradleUserHomeOption(gradleOptions string) string {
+	options, err := shellquote.Split(gradleOptions)
+	if err != nil {
+		return ""
+	}
+
+	var systemProperty string
+	for i, option := range options {
+		if value := strings.TrimPrefix(option, "--gradle-user-home="); value != option {
+			return value
+		}
+		if (option == "-g" || option == "--gradle-user-home") && i+1 < len(options) {
+			return options[i+1]
+		}
+
+		// Like: -Dgradle.user.home=/mnt/gradle, -D gradle.user.home=/mnt/gradle or --system-prop gradle.user.home=/mnt/gradle
+		property := strings.TrimPrefix(strings.TrimPrefix(option, "--system-prop="), "-D")
+		if (option == "-D" || option == "--system-prop") && i+1 < len(options) {
+			property = options[i+1]
+		}
+		if value := strings.TrimPrefix(property, "gradle.user.home="); value != property {
+			systemProperty = value
+		}
+	}
+	return systemProperty
+}
+
+// resolveGradleUserHome returns the absolute path of the Gradle user home, set by (in order of precedence)
+// the Gradle options (as Gradle uses that), the gradle_user_home input, the GRADLE_USER_HOME environment variable
+// or defaulting to ~/.gradle. Gradle runs in buildRootAbs, the relative paths of the options and the environment variable are resolved from there,
+// the relative input is resolved from the working directory.
+func resolveGradleUserHome(input, gradleOptions, buildRootAbs string) (string, error) {
+	home, baseDir := gradleUserHomeOption(gradleOptions), buildRootAbs
+	if home == "" {
+		home, baseDir = input, ""
+	}
+	if home == "" {
+		home, baseDir = os.Getenv(gradleUserHomeEnvKey), buildRootAbs
+	}
+	if home == "" {
+		return filepath.Join(pathutil.UserHomeDir(), ".gradle"), nil
+	}
+
+	if strings.HasPrefix(home, "~/") {
+		home = filepath.Join(pathutil.UserHomeDir(), home[2:])
+	} else if !filepath.IsAbs(home) && baseDir != "" {
+		home = filepath.Join(baseDir, home)
+	}
+	return filepath.Abs(home)
+}
+
+// gradleUserHomeArgs passes the resolved Gradle user home to Gradle, unless the Gradle options already set it.
+func gradleUserHomeArgs(gradleUserHome, gradleOptions string) []string {
+	if gradleUserHomeOption(gradleOptions) != "" {
+		return nil
+	}
+	return []string{"--gradle-user-home", gradleUserHome}
+}
+
+// displayHomePath shortens the paths inside the user's home to ~/..., like ~/.gradle/caches.
+func displayHomePath(homeDir, pth string) string {
+	if rel, err := filepath.Rel(homeDir, pth); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.Join("~", rel)
+	}
+	return pth
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveGradleUserHome(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		gradleOptions string
+		env           string
+		want          string
+	}{
+		{
+			name: "default",
+			want: filepath.Join(pathutil.UserHomeDir(), ".gradle"),
+		},
+		{
+			name: "environment variable",
+			env:  "/mnt/gradle",
+			want: "/mnt/gradle",
+		},
+		{
+			name:  "input",
+			input: "~/gradle-home",
+			env:   "/mnt/gradle",
+			want:  filepath.Join(pathutil.UserHomeDir(), "gradle-home"),
+		},
+		{
+			name:          "gradle options",
+			input:         "/mnt/input",
+			gradleOptions: "--stacktrace --gradle-user-home=/mnt/option",
+			want:          "/mnt/option",
+		},
+		{
+			name:          "short gradle option",
+			gradleOptions: "-g /mnt/option --stacktrace",
+			want:          "/mnt/option",
+		},
+		{
+			name:          "relative gradle option",
+			input:         "input-home",
+			gradleOptions: "-g .gradle-home",
+			want:          "/project/.gradle-home",
+		},
+		{
+			name:          "system property",
+			input:         "/mnt/input",
+			gradleOptions: "-Dgradle.user.home=/mnt/property --stacktrace",
+			want:          "/mnt/property",
+		},
+		{
+			name:          "gradle option over system property",
+			gradleOptions: "--system-prop gradle.user.home=/mnt/property -g /mnt/option",
+			want:          "/mnt/option",
+		},
+		{
+			name: "relative environment variable",
+			env:  "gradle-home",
+			want: "/project/gradle-home",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(gradleUserHomeEnvKey, tt.env)
+
+			got, err := resolveGradleUserHome(tt.input, tt.gradleOptions, "/project")
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGradleUserHomeArgs(t *testing.T) {
+	require.Equal(t, []string{"--gradle-user-home", "/mnt/gradle"}, gradleUserHomeArgs("/mnt/gradle", "--stacktrace"))
+	require.Nil(t, gradleUserHomeArgs("/mnt/gradle", "-g /mnt/gradle"))
+	require.Nil(t, gradleUserHomeArgs("/mnt/gradle", "-Dgradle.user.home=/mnt/gradle"))
+}
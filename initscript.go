@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeInitScript writes a Gradle init script into dir, to be passed to Gradle with `--init-script`.
+func writeInitScript(dir, name, content string) (string, error) {
+	pth := filepath.Join(dir, name)
+	if err := os.WriteFile(pth, []byte(content), 0600); err != nil {
+		return "", fmt.Errorf("failed to write init script (%s): %w", pth, err)
+	}
+	return pth, nil
+}
+
+// groovyString returns s as a single-quoted Groovy string literal.
+func groovyString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
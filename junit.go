@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// Gradle writes a JUnit XML report per test class, like:
+// app/build/test-results/testDebugUnitTest/TEST-io.bitrise.sample.MainTest.xml
+var junitResultPatterns = filePatterns{
+	include: []string{"*test-results/*TEST-*.xml"},
+}
+
+type testCase struct {
+	Module    string
+	Task      string
+	ClassName string
+	Name      string
+	Failed    bool
+	Skipped   bool
+	Duration  float64
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name `xml:"testsuite"`
+	Name      string   `xml:"name,attr"`
+	Time      float64  `xml:"time,attr"`
+	TestCases []struct {
+		Name      string    `xml:"name,attr"`
+		ClassName string    `xml:"classname,attr"`
+		Time      float64   `xml:"time,attr"`
+		Failure   *struct{} `xml:"failure"`
+		Error     *struct{} `xml:"error"`
+		Skipped   *struct{} `xml:"skipped"`
+	} `xml:"testcase"`
+}
+
+func parseJUnitResult(pth string) (junitTestSuite, error) {
+	var suite junitTestSuite
+
+	content, err := os.ReadFile(pth)
+	if err != nil {
+		return suite, err
+	}
+
+	if err := xml.Unmarshal(content, &suite); err != nil {
+		return suite, fmt.Errorf("failed to parse test result (%s): %w", pth, err)
+	}
+
+	return suite, nil
+}
+
+// findTestResults collects the test cases of the JUnit XML reports under buildRootDir,
+// which were written after the given time.
+func findTestResults(buildRootDir string, modifiedAfter time.Time) ([]testCase, error) {
+	reports, err := findArtifacts(buildRootDir, junitResultPatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	var testCases []testCase
+	for _, report := range reports {
+		fi, err := os.Stat(report)
+		if err != nil {
+			return nil, err
+		}
+		if fi.ModTime().Before(modifiedAfter) {
+			continue
+		}
+
+		suite, err := parseJUnitResult(report)
+		if err != nil {
+			log.Warnf("%s", err)
+			continue
+		}
+
+		module := gradleModuleForPath(buildRootDir, report)
+		task := filepath.Base(filepath.Dir(report))
+		for _, tc := range suite.TestCases {
+			className := tc.ClassName
+			if className == "" {
+				className = suite.Name
+			}
+
+			testCases = append(testCases, testCase{
+				Module:    module,
+				Task:      task,
+				ClassName: className,
+				Name:      tc.Name,
+				Failed:    tc.Failure != nil || tc.Error != nil,
+				Skipped:   tc.Skipped != nil,
+				Duration:  tc.Time,
+			})
+		}
+	}
+
+	return testCases, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const junitResultContent = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="io.bitrise.sample.CalculatorTest" tests="3" skipped="1" failures="1" errors="0" timestamp="2024-01-01T10:00:00" hostname="localhost" time="0.5">
+  <properties/>
+  <testcase name="sum()" classname="io.bitrise.sample.CalculatorTest" time="0.1"/>
+  <testcase name="divide()" classname="io.bitrise.sample.CalculatorTest" time="0.3">
+    <failure message="expected: 2 but was: 3" type="org.opentest4j.AssertionFailedError">stacktrace</failure>
+  </testcase>
+  <testcase name="multiply()" classname="io.bitrise.sample.CalculatorTest" time="0.0">
+    <skipped/>
+  </testcase>
+  <system-out><![CDATA[]]></system-out>
+  <system-err><![CDATA[]]></system-err>
+</testsuite>
+`
+
+func writeTestFile(t *testing.T, pth, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0755))
+	require.NoError(t, os.WriteFile(pth, []byte(content), 0644))
+}
+
+func TestFindTestResults(t *testing.T) {
+	buildRootDir := t.TempDir()
+	writeTestFile(t, filepath.Join(buildRootDir, "app/build/test-results/testDebugUnitTest/TEST-io.bitrise.sample.CalculatorTest.xml"), junitResultContent)
+	writeTestFile(t, filepath.Join(buildRootDir, "app/build/test-results/testDebugUnitTest/binary/output.bin"), "")
+
+	stalePth := filepath.Join(buildRootDir, "lib/build/test-results/test/TEST-io.bitrise.lib.StaleTest.xml")
+	writeTestFile(t, stalePth, junitResultContent)
+	staleTime := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(stalePth, staleTime, staleTime))
+
+	got, err := findTestResults(buildRootDir, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+
+	want := []testCase{
+		{Module: ":app", Task: "testDebugUnitTest", ClassName: "io.bitrise.sample.CalculatorTest", Name: "sum()", Duration: 0.1},
+		{Module: ":app", Task: "testDebugUnitTest", ClassName: "io.bitrise.sample.CalculatorTest", Name: "divide()", Failed: true, Duration: 0.3},
+		{Module: ":app", Task: "testDebugUnitTest", ClassName: "io.bitrise.sample.CalculatorTest", Name: "multiply()", Skipped: true},
+	}
+	require.Equal(t, want, got)
+}
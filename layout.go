@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	buildRootDirEnvKey = "BITRISE_GRADLE_BUILD_ROOT_DIR"
+	gradlewPathEnvKey  = "BITRISE_GRADLEW_PATH"
+
+	// projectDiscoveryMaxDepth limits the search in large repositories, Gradle projects are rarely nested deeper.
+	projectDiscoveryMaxDepth = 5
+)
+
+var settingsFileNames = []string{"settings.gradle", "settings.gradle.kts"}
+
+func hasSettingsFile(dir string) bool {
+	for _, name := range settingsFileNames {
+		if isFile(filepath.Join(dir, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// repositoryRoot returns the root of the git repository of workDir, or workDir if it is not in a git repository.
+func repositoryRoot(workDir string) string {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = workDir
+	out, err := cmd.Output()
+	if err != nil {
+		return workDir
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// findGradleProjects returns the directories (relative to searchRoot) which have a settings script and gradlew.
+func findGradleProjects(searchRoot string) ([]string, error) {
+	var projects []string
+	err := filepath.Walk(searchRoot, func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to walk %s: %w", pth, err)
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(searchRoot, pth)
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			if info.Name() == "build" || info.Name() == "node_modules" || strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if strings.Count(rel, string(os.PathSeparator)) >= projectDiscoveryMaxDepth {
+				return filepath.SkipDir
+			}
+		}
+
+		if hasSettingsFile(pth) && isFile(filepath.Join(pth, "gradlew")) {
+			projects = append(projects, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(projects)
+	return projects, nil
+}
+
+// discoverGradleProject returns the only Gradle project of searchRoot,
+// or an error listing the candidates if there is more than one.
+func discoverGradleProject(searchRoot string) (string, error) {
+	projects, err := findGradleProjects(searchRoot)
+	if err != nil {
+		return "", err
+	}
+
+	switch len(projects) {
+	case 0:
+		return "", fmt.Errorf("no Gradle project (settings.gradle or settings.gradle.kts next to gradlew) found in %s", searchRoot)
+	case 1:
+		return filepath.Join(searchRoot, projects[0]), nil
+	default:
+		return "", fmt.Errorf("found %d Gradle projects in %s, set build_root_directory and gradlew_path to one of them:\n%s", len(projects), searchRoot, formatGradleProjects(projects))
+	}
+}
+
+func formatGradleProjects(projects []string) string {
+	var candidates []string
+	for _, project := range projects {
+		candidates = append(candidates, "- "+filepath.Join(project, "gradlew"))
+	}
+	return strings.Join(candidates, "\n")
+}
+
+// missingGradlewError is returned if build_root_directory exists, but gradlew doesn't.
+// The project is not discovered in that case, the error lists the Gradle projects of the repository instead.
+func missingGradlewError(buildRootAbs, gradlewPath string) error {
+	searchRoot := repositoryRoot(buildRootAbs)
+	projects, err := findGradleProjects(searchRoot)
+	if err != nil || len(projects) == 0 {
+		return fmt.Errorf("gradlew does not exist at: %s", gradlewPath)
+	}
+	return fmt.Errorf("gradlew does not exist at: %s, set build_root_directory and gradlew_path to one of the Gradle projects in %s:\n%s", gradlewPath, searchRoot, formatGradleProjects(projects))
+}
+
+// exportProjectLayout exports the build root directory and the project's gradlew path,
+// gradlewPath is empty if the Step runs a Gradle distribution instead of the wrapper.
+func exportProjectLayout(buildRootAbs, gradlewPath string) error {
+	envs := map[string]string{buildRootDirEnvKey: buildRootAbs}
+	if gradlewPath != "" {
+		envs[gradlewPathEnvKey] = gradlewPath
+	}
+	for key, value := range envs {
+		if err := exportEnvironmentWithEnvman(key, value); err != nil {
+			return fmt.Errorf("failed to export environment (%s): %w", key, err)
+		}
+	}
+
+	log.Printf("Build root directory: %s", buildRootAbs)
+	if gradlewPath != "" {
+		log.Printf("gradlew: %s", gradlewPath)
+	}
+	return nil
+}
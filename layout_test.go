@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createGradleProject(t *testing.T, dir, settingsName string, withGradlew bool) {
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, settingsName), []byte(`include(":app")`), 0644))
+	if withGradlew {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "gradlew"), []byte("#!/bin/sh\n"), 0755))
+	}
+}
+
+func TestFindGradleProjects(t *testing.T) {
+	root := t.TempDir()
+	createGradleProject(t, filepath.Join(root, "android"), "settings.gradle", true)
+	createGradleProject(t, filepath.Join(root, "backend"), "settings.gradle.kts", true)
+	// Not a candidate: no gradlew
+	createGradleProject(t, filepath.Join(root, "android", "build-logic"), "settings.gradle.kts", false)
+	// Not candidates: skipped directories
+	createGradleProject(t, filepath.Join(root, "node_modules", "lib", "android"), "settings.gradle", true)
+	createGradleProject(t, filepath.Join(root, ".git", "modules"), "settings.gradle", true)
+	createGradleProject(t, filepath.Join(root, "android", "build", "tmp"), "settings.gradle", true)
+	// Not a candidate: too deep
+	createGradleProject(t, filepath.Join(root, "a", "b", "c", "d", "e", "f"), "settings.gradle", true)
+
+	projects, err := findGradleProjects(root)
+	require.NoError(t, err)
+	require.Equal(t, []string{"android", "backend"}, projects)
+}
+
+func TestDiscoverGradleProject(t *testing.T) {
+	root := t.TempDir()
+
+	_, err := discoverGradleProject(root)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no Gradle project")
+
+	createGradleProject(t, filepath.Join(root, "android"), "settings.gradle", true)
+	dir, err := discoverGradleProject(root)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(root, "android"), dir)
+
+	createGradleProject(t, filepath.Join(root, "samples", "demo"), "settings.gradle.kts", true)
+	_, err = discoverGradleProject(root)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "found 2 Gradle projects")
+	require.Contains(t, err.Error(), "- android/gradlew\n- samples/demo/gradlew")
+}
+
+func TestResolveGradlewPathNotFound(t *testing.T) {
+	root := t.TempDir()
+
+	_, err := resolveGradlewPath(filepath.Join(root, "missing"), "gradlew", true)
+	require.ErrorAs(t, err, new(projectNotFoundError))
+
+	// The build root exists: the project is not discovered, the candidates are listed
+	_, err = resolveGradlewPath(root, "gradlew", true)
+	require.EqualError(t, err, "gradlew does not exist at: "+filepath.Join(root, "gradlew"))
+	require.False(t, errors.As(err, new(projectNotFoundError)))
+
+	createGradleProject(t, filepath.Join(root, "android"), "settings.gradle", true)
+	_, err = resolveGradlewPath(root, "gradlew", true)
+	require.EqualError(t, err, "gradlew does not exist at: "+filepath.Join(root, "gradlew")+
+		", set build_root_directory and gradlew_path to one of the Gradle projects in "+root+":\n- android/gradlew")
+	require.False(t, errors.As(err, new(projectNotFoundError)))
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "gradlew"), []byte("#!/bin/sh\n"), 0755))
+	_, err = resolveGradlewPath(root, "gradlew", true)
+	require.Error(t, err)
+	require.False(t, errors.As(err, new(projectNotFoundError)))
+}
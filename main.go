@@ -1,13 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/bitrise-io/go-android/cache"
 	utilscache "github.com/bitrise-io/go-steputils/cache"
 	"github.com/bitrise-io/go-steputils/commandhelper"
 	"github.com/bitrise-io/go-steputils/stepconf"
@@ -30,7 +32,33 @@ type Config struct {
 	BuildRootDirectory string `env:"build_root_directory,required"`
 	GradleTasks        string `env:"gradle_task,required"`
 	GradlewPath        string `env:"gradlew_path"`
+	GradlewAutoFix     bool   `env:"gradlew_auto_fix,opt[yes,no]"`
+	GradleLauncher     string `env:"gradle_launcher,opt[wrapper,auto,distribution]"`
 	GradleOptions      string `env:"gradle_options"`
+	GradleUserHome     string `env:"gradle_user_home"`
+	// Gradle distribution
+	GradleVersion               string `env:"gradle_version"`
+	GradleDistributionsDir      string `env:"gradle_distributions_dir"`
+	GradleDistributionMirrorURL string `env:"gradle_distribution_mirror_url"`
+	// Variant
+	Module         string `env:"module"`
+	BuildType      string `env:"build_type"`
+	ProductFlavors string `env:"product_flavors"`
+	ArtifactKind   string `env:"artifact_kind,opt[apk,aab,both]"`
+	// Version
+	VersionCode       string `env:"version_code"`
+	VersionCodeOffset int    `env:"version_code_offset"`
+	VersionName       string `env:"version_name"`
+	// Signing
+	KeystoreURL        stepconf.Secret `env:"keystore_url"`
+	KeystorePassword   stepconf.Secret `env:"keystore_password"`
+	KeystoreAlias      string          `env:"keystore_alias"`
+	PrivateKeyPassword stepconf.Secret `env:"private_key_password"`
+	// Wrapper validation
+	WrapperValidation         string `env:"wrapper_validation,opt[off,warn,fail]"`
+	WrapperExtraChecksums     string `env:"wrapper_extra_checksums"`
+	WrapperDistributionPolicy string `env:"wrapper_distribution_policy,opt[off,warn,fail]"`
+	WrapperDistributionHosts  string `env:"wrapper_distribution_hosts"`
 	// Export config
 	AppFileIncludeFilter     string `env:"app_file_include_filter,required"`
 	AppFileExcludeFilter     string `env:"app_file_exclude_filter"`
@@ -39,6 +67,44 @@ type Config struct {
 	MappingFileIncludeFilter string `env:"mapping_file_include_filter"`
 	MappingFileExcludeFilter string `env:"mapping_file_exclude_filter"`
 
+	// Test
+	RetryFailedTests bool   `env:"retry_failed_tests,opt[yes,no]"`
+	ShardIndex       int    `env:"shard_index"`
+	ShardCount       int    `env:"shard_count"`
+	TestTimingsPath  string `env:"test_timings_path"`
+
+	// Affected modules
+	AffectedModulesBaseRef string `env:"affected_modules_base_ref"`
+
+	// Insights
+	WarningMode         string `env:"warning_mode,opt[summary,all,fail]"`
+	CollectTaskOutcomes bool   `env:"collect_task_outcomes,opt[yes,no]"`
+	ProfileTasks        bool   `env:"profile_tasks,opt[yes,no]"`
+	ProfileTopTasks     int    `env:"profile_top_tasks"`
+	AnalyzeCriticalPath bool   `env:"analyze_critical_path,opt[yes,no]"`
+	ExportProjectModel  bool   `env:"export_project_model,opt[yes,no]"`
+
+	// Configuration cache
+	ConfigurationCache string `env:"configuration_cache,opt[off,warn,fail]"`
+
+	// Coverage
+	CollectCoverage        bool    `env:"collect_coverage,opt[yes,no]"`
+	CoverageMinimumPercent float64 `env:"coverage_minimum_percent,range[0..100]"`
+	CoverageBaselinePath   string  `env:"coverage_baseline_path"`
+	CoverageMaxDropPercent float64 `env:"coverage_max_drop_percent,range[0..100]"`
+
+	// Cache
+	PruneGradleCache             bool            `env:"prune_gradle_cache,opt[yes,no]"`
+	GradleCacheRetentionDays     int             `env:"gradle_cache_retention_days"`
+	CacheSizeBudgetMB            int             `env:"cache_size_budget_mb"`
+	LocalBuildCache              bool            `env:"local_build_cache,opt[yes,no]"`
+	LocalBuildCacheDir           string          `env:"local_build_cache_dir"`
+	LocalBuildCacheRetentionDays int             `env:"local_build_cache_retention_days"`
+	RemoteBuildCacheURL          string          `env:"remote_build_cache_url"`
+	RemoteBuildCacheUsername     string          `env:"remote_build_cache_username"`
+	RemoteBuildCachePassword     stepconf.Secret `env:"remote_build_cache_password"`
+	RemoteBuildCachePush         bool            `env:"remote_build_cache_push,opt[yes,no]"`
+
 	// Debug
 	CacheLevel string `env:"cache_level,opt['all','only_deps','none']"`
 
@@ -46,23 +112,24 @@ type Config struct {
 	DeployDir string `env:"BITRISE_DEPLOY_DIR"`
 }
 
-func runGradleTask(gradleTool, tasks, options, workDir, destDir string) error {
+func runGradleTask(gradleTool, tasks, options string, extraArgs []string, workDir, destDir string) (string, error) {
 	optionSlice, err := shellquote.Split(options)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	taskSlice, err := shellquote.Split(tasks)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	cmdSlice := []string{gradleTool}
 	cmdSlice = append(cmdSlice, taskSlice...)
 	cmdSlice = append(cmdSlice, optionSlice...)
+	cmdSlice = append(cmdSlice, extraArgs...)
 
 	fmt.Println()
-	log.Donef("$ %s", command.PrintableCommandArgs(false, cmdSlice))
+	log.Donef("$ %s", command.PrintableCommandArgs(false, redactArgs(cmdSlice)))
 	fmt.Println()
 
 	cmd := command.New(cmdSlice[0], cmdSlice[1:]...)
@@ -70,20 +137,27 @@ func runGradleTask(gradleTool, tasks, options, workDir, destDir string) error {
 
 	if shouldSaveOutputToLogFile(optionSlice) { // Do not write to stdout as debug log may contain sensitive information
 		rawOutputLogPath := filepath.Join(destDir, rawGradleResultFileName)
-		return commandhelper.RunAndExportOutput(*cmd, rawOutputLogPath, bitriseGradleResultsTextEnvKey, 20)
+		runErr := commandhelper.RunAndExportOutput(*cmd, rawOutputLogPath, bitriseGradleResultsTextEnvKey, 20)
+		output, err := os.ReadFile(rawOutputLogPath)
+		if err != nil {
+			log.Warnf("Failed to read Gradle output: %s", err)
+		}
+		return string(output), runErr
 	}
 
-	cmd.SetStdout(os.Stdout)
-	cmd.SetStderr(os.Stderr)
+	// The output is kept for the post-build analysis (task outcomes, warnings) besides streaming it to the log.
+	var output bytes.Buffer
+	cmd.SetStdout(io.MultiWriter(os.Stdout, &output))
+	cmd.SetStderr(io.MultiWriter(os.Stderr, &output))
 	if err := cmd.Run(); err != nil {
 		if errorutil.IsExitStatusError(err) {
-			return err
+			return output.String(), err
 		}
 
-		return fmt.Errorf("could not run gradlew command: %v", err)
+		return output.String(), fmt.Errorf("could not run gradlew command: %v", err)
 	}
 
-	return nil
+	return output.String(), nil
 }
 
 func shouldSaveOutputToLogFile(options []string) bool {
@@ -148,12 +222,30 @@ func exportEnvironmentWithEnvman(keyStr, valueStr string) error {
 	return cmd.Run()
 }
 
+// cleanups remove the temporary files of the Step when it exits,
+// failf runs them too, as os.Exit doesn't run the deferred functions.
+var cleanups []func()
+
+func addCleanup(cleanup func()) {
+	cleanups = append(cleanups, cleanup)
+}
+
+func runCleanups() {
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+	cleanups = nil
+}
+
 func failf(message string, args ...interface{}) {
 	log.Errorf(message, args...)
+	runCleanups()
 	os.Exit(1)
 }
 
 func main() {
+	defer runCleanups()
+
 	var configs Config
 	if err := stepconf.Parse(&configs); err != nil {
 		failf("Issue with input: %s", err)
@@ -161,32 +253,431 @@ func main() {
 	stepconf.Print(configs)
 	fmt.Println()
 
-	gradlewPath, err := resolveGradlewPath(configs.BuildRootDirectory, configs.GradlewPath)
+	buildRootAbs, err := filepath.Abs(configs.BuildRootDirectory)
 	if err != nil {
-		failf("Failed to resolve gradlew path: %s", err)
+		failf("Can't get absolute path for build_root_directory (%s): %s", configs.BuildRootDirectory, err)
 	}
 
-	buildRootAbs, err := filepath.Abs(configs.BuildRootDirectory)
+	gradleUserHome, err := resolveGradleUserHome(configs.GradleUserHome, configs.GradleOptions, buildRootAbs)
 	if err != nil {
-		failf("Can't get absolute path for build_root_directory (%s): %s", configs.BuildRootDirectory, err)
+		failf("Failed to resolve Gradle user home: %s", err)
 	}
+	log.Printf("Gradle user home: %s", gradleUserHome)
 
-	if err := os.Chmod(gradlewPath, 0770); err != nil {
-		failf("Failed to add executable permission on gradlew file (%s): %s", gradlewPath, err)
+	// gradlePath is the launcher the Step runs: gradlew, its repaired copy or the distribution's gradle
+	var gradlePath, gradlewPath, repairedGradlewPath, gradleVersion string
+	if gradlewPath = joinGradlewPath(buildRootAbs, configs.GradlewPath); useGradleDistribution(configs.GradleLauncher, gradlewPath) {
+		if _, err := os.Stat(buildRootAbs); err != nil {
+			failf("build_root_directory does not exist at: %s", buildRootAbs)
+		}
+
+		fmt.Println()
+		log.Infof("Provisioning Gradle distribution...")
+		gradleVersion, err = distributionVersion(configs.GradleVersion, gradlewPath)
+		if err != nil {
+			failf("Failed to get the Gradle version: %s", err)
+		}
+		gradlePath, err = provisionGradleDistribution(gradleVersion, configs.GradleDistributionsDir, configs.GradleDistributionMirrorURL, gradleUserHome)
+		if err != nil {
+			failf("Failed to provision Gradle %s: %s", gradleVersion, err)
+		}
+		log.Donef("Using Gradle %s: %s", gradleVersion, gradlePath)
+		// The project's gradlew (if any) is not run, so it is not exported
+		gradlewPath = ""
+	} else {
+		gradlePath, err = resolveGradlewPath(configs.BuildRootDirectory, configs.GradlewPath, configs.GradlewAutoFix)
+		var notFoundErr projectNotFoundError
+		if errors.As(err, &notFoundErr) {
+			log.Warnf("%s", err)
+			log.Printf("Searching for the Gradle project...")
+
+			workDir, wdErr := os.Getwd()
+			if wdErr != nil {
+				failf("Failed to get working directory: %s", wdErr)
+			}
+			buildRootAbs, err = discoverGradleProject(repositoryRoot(workDir))
+			if err != nil {
+				failf("Failed to find the Gradle project: %s", err)
+			}
+			log.Donef("Found Gradle project: %s", buildRootAbs)
+
+			if gradleUserHome, err = resolveGradleUserHome(configs.GradleUserHome, configs.GradleOptions, buildRootAbs); err != nil {
+				failf("Failed to resolve Gradle user home: %s", err)
+			}
+			log.Printf("Gradle user home: %s", gradleUserHome)
+
+			gradlewPath = joinGradlewPath(buildRootAbs, "gradlew")
+			gradlePath, err = resolveGradlewPath(buildRootAbs, "gradlew", configs.GradlewAutoFix)
+		}
+		if err != nil {
+			failf("Failed to resolve gradlew path: %s", err)
+		}
+		if gradlePath != gradlewPath {
+			repairedGradlewPath = gradlePath
+			addCleanup(func() { removeRepairedGradlew(repairedGradlewPath) })
+		}
+
+		fmt.Println()
+		log.Infof("Validating Gradle wrapper...")
+		if err := validateWrapper(gradlePath, configs); err != nil {
+			failf("Gradle wrapper validation failed: %s", err)
+		}
+
+		if err := os.Chmod(gradlePath, 0770); err != nil {
+			failf("Failed to add executable permission on gradlew file (%s): %s", gradlePath, err)
+		}
+
+		gradleVersion, err = gradleWrapperVersion(gradlePath)
+		if err != nil {
+			log.Warnf("Failed to get the project's Gradle version, caches of other Gradle versions are not excluded or pruned: %s", err)
+		}
+	}
+
+	if err := exportProjectLayout(buildRootAbs, gradlewPath); err != nil {
+		failf("%s", err)
+	}
+	fmt.Println()
+
+	if configs.ExportProjectModel {
+		log.Infof("Exporting project model...")
+		if err := processProjectModel(buildRootAbs, configs.DeployDir); err != nil {
+			log.Warnf("Failed to export project model: %s", err)
+		}
+		fmt.Println()
 	}
 
 	gradleStarted := time.Now()
 
-	log.Infof("Running gradle task...")
-	if err := runGradleTask(gradlewPath, configs.GradleTasks, configs.GradleOptions, buildRootAbs, configs.DeployDir); err != nil {
-		failf("Gradle task failed: %s", err)
+	tasks := configs.GradleTasks
+	var variant *buildVariant
+	if configs.BuildType != "" {
+		log.Infof("Composing variant tasks...")
+		model, err := parseProjectModel(buildRootAbs)
+		if err != nil {
+			failf("Failed to read the project: %s", err)
+		}
+		variant, err = newBuildVariant(configs.Module, configs.BuildType, configs.ProductFlavors, configs.ArtifactKind, *model)
+		if err != nil {
+			failf("Invalid variant: %s", err)
+		}
+
+		available, err := availableTasks(gradlePath, buildRootAbs, variant.Module, configs.GradleOptions, gradleUserHomeArgs(gradleUserHome, configs.GradleOptions))
+		if err != nil {
+			failf("Failed to list the available tasks to validate the variant: %s", err)
+		}
+		if err := validateVariantTasks(*variant, available); err != nil {
+			failf("Invalid variant: %s", err)
+		}
+
+		tasks = shellquote.Join(variant.Tasks()...)
+		log.Printf("Variant %s, tasks: %s", variant.Name(), tasks)
+		fmt.Println()
+	} else if configs.Module != "" || configs.ProductFlavors != "" {
+		failf("Issue with input: build_type is required to compose the tasks of module and product_flavors")
+	}
+
+	if configs.AffectedModulesBaseRef != "" && configs.ShardCount > 1 {
+		log.Warnf("Test sharding is enabled, affected module selection is skipped")
+	} else if configs.AffectedModulesBaseRef != "" {
+		log.Infof("Selecting affected modules...")
+		affectedTasks, err := selectAffectedTasks(buildRootAbs, configs.AffectedModulesBaseRef, tasks)
+		if err != nil {
+			log.Warnf("Failed to select the affected modules, running the full build: %s", err)
+		} else {
+			tasks = affectedTasks
+		}
+		if tasks == "" {
+			log.Warnf("No module is affected by the changes, skipping the Gradle task")
+		} else {
+			log.Printf("Tasks: %s", tasks)
+		}
+		if err := exportEnvironmentWithEnvman(affectedTasksEnvKey, tasks); err != nil {
+			failf("Failed to export environment (%s): %s", affectedTasksEnvKey, err)
+		}
+		fmt.Println()
+	}
+	if configs.ShardCount > 1 {
+		log.Infof("Splitting tests (shard %d of %d)...", configs.ShardIndex, configs.ShardCount)
+		tasks, err = testShardTasks(configs, buildRootAbs, tasks)
+		if err != nil {
+			failf("Failed to split tests: %s", err)
+		}
+		if tasks == "" {
+			log.Warnf("No test class assigned to this shard, skipping the Gradle task")
+		}
+	}
+
+	stepTmpDir, err := os.MkdirTemp("", "gradle-runner")
+	if err != nil {
+		failf("Failed to create temp dir: %s", err)
+	}
+	addCleanup(func() {
+		if err := os.RemoveAll(stepTmpDir); err != nil {
+			log.Warnf("Failed to remove temp dir (%s): %s", stepTmpDir, err)
+		}
+	})
+
+	gradleArgs := gradleUserHomeArgs(gradleUserHome, configs.GradleOptions)
+	var taskEventsPth string
+	if configs.CollectTaskOutcomes || configs.ProfileTasks || configs.AnalyzeCriticalPath {
+		taskEventsPth = filepath.Join(stepTmpDir, taskEventsFileName)
+		initScriptPth, err := writeInitScript(stepTmpDir, taskEventsInitScriptName, taskEventsInitScript(taskEventsPth))
+		if err != nil {
+			failf("Failed to create init script: %s", err)
+		}
+		gradleArgs = append(gradleArgs, "--init-script", initScriptPth)
+	}
+
+	gradleArgs = append(gradleArgs, warningModeArgs(configs.WarningMode, configs.GradleOptions)...)
+	gradleArgs = append(gradleArgs, configurationCacheArgs(configs.ConfigurationCache, configs.GradleOptions)...)
+
+	if configs.LocalBuildCache {
+		if configs.LocalBuildCacheDir == "" {
+			failf("Issue with input: local_build_cache_dir is required if local_build_cache is enabled")
+		}
+		if configs.LocalBuildCacheRetentionDays < 1 {
+			failf("Issue with input: local_build_cache_retention_days (%d) should be at least 1", configs.LocalBuildCacheRetentionDays)
+		}
+		if configs.LocalBuildCacheDir, err = filepath.Abs(configs.LocalBuildCacheDir); err != nil {
+			failf("Can't get absolute path for local_build_cache_dir (%s): %s", configs.LocalBuildCacheDir, err)
+		}
+		initScriptPth, err := writeInitScript(stepTmpDir, localBuildCacheInitScriptName, localBuildCacheInitScript(configs.LocalBuildCacheDir, configs.LocalBuildCacheRetentionDays))
+		if err != nil {
+			failf("Failed to create init script: %s", err)
+		}
+		gradleArgs = append(gradleArgs, "--init-script", initScriptPth)
+	} else if configs.CacheLevel == string(utilscache.LevelAll) &&
+		!buildCacheEnabled(buildRootAbs, gradleUserHome, configs.GradleOptions) {
+		log.Warnf("cache_level is set to `all`, but the Gradle build cache is disabled: enable local_build_cache or set org.gradle.caching=true in gradle.properties")
+	}
+
+	if configs.RemoteBuildCacheURL != "" {
+		log.Infof("Checking remote build cache...")
+		if err := preflightRemoteBuildCache(configs.RemoteBuildCacheURL, configs.RemoteBuildCacheUsername, string(configs.RemoteBuildCachePassword)); err != nil {
+			log.Warnf("[remote build cache] %s", err)
+		} else {
+			log.Donef("Remote build cache is available")
+		}
+
+		for key, value := range map[string]string{
+			remoteBuildCacheUsernameEnvKey: configs.RemoteBuildCacheUsername,
+			remoteBuildCachePasswordEnvKey: string(configs.RemoteBuildCachePassword),
+		} {
+			if err := os.Setenv(key, value); err != nil {
+				failf("Failed to set environment variable (%s): %s", key, err)
+			}
+		}
+
+		initScriptPth, err := writeInitScript(stepTmpDir, remoteBuildCacheInitScriptName, remoteBuildCacheInitScript(configs.RemoteBuildCacheURL, configs.RemoteBuildCachePush))
+		if err != nil {
+			failf("Failed to create init script: %s", err)
+		}
+		gradleArgs = append(gradleArgs, "--init-script", initScriptPth)
+	}
+
+	versionCode, err := resolveVersionCode(configs.VersionCode, configs.VersionCodeOffset)
+	if err != nil {
+		failf("Issue with input: %s", err)
+	}
+	if versionCode != 0 || configs.VersionName != "" {
+		if versionCode != 0 {
+			log.Printf("Injecting version code: %d", versionCode)
+		}
+		if configs.VersionName != "" {
+			log.Printf("Injecting version name: %s", configs.VersionName)
+		}
+		initScriptPth, err := writeInitScript(stepTmpDir, versionInitScriptName, versionInitScript(versionCode, configs.VersionName))
+		if err != nil {
+			failf("Failed to create init script: %s", err)
+		}
+		gradleArgs = append(gradleArgs, "--init-script", initScriptPth)
+	}
+
+	if configs.LocalBuildCache || configs.RemoteBuildCacheURL != "" {
+		gradleArgs = append(gradleArgs, buildCacheArgs(configs.GradleOptions)...)
+	}
+
+	var cachePruning *gradleCachePruning
+	if configs.PruneGradleCache && configs.CacheLevel != string(utilscache.LevelNone) {
+		if configs.GradleCacheRetentionDays < 1 {
+			failf("Issue with input: gradle_cache_retention_days (%d) should be at least 1", configs.GradleCacheRetentionDays)
+		}
+		cachePruning, err = prepareGradleCachePruning(gradleUserHome, configs.GradleCacheRetentionDays)
+		if err != nil {
+			log.Warnf("Gradle cache pruning disabled: %s", err)
+		} else {
+			// The init script is in the Gradle user home, it would keep pruning in the later builds
+			addCleanup(cachePruning.removeInitScript)
+		}
+	}
+
+	var keystorePth string
+	var signingCertificate []byte
+	if configs.KeystoreURL != "" {
+		if configs.KeystorePassword == "" || configs.KeystoreAlias == "" {
+			failf("Issue with input: keystore_password and keystore_alias are required if keystore_url is set")
+		}
+		keyPassword := configs.PrivateKeyPassword
+		if keyPassword == "" {
+			keyPassword = configs.KeystorePassword
+		}
+
+		log.Infof("Preparing keystore...")
+		keystorePth = filepath.Join(stepTmpDir, keystoreFileName)
+		if err := prepareKeystore(string(configs.KeystoreURL), keystorePth); err != nil {
+			failf("Failed to prepare the keystore: %s", err)
+		}
+		signingCertificate, err = keystoreCertificate(keystorePth, configs.KeystoreAlias, string(configs.KeystorePassword))
+		if err != nil {
+			failf("Failed to read the certificate of %s from the keystore: %s", configs.KeystoreAlias, err)
+		}
+		log.Printf("Signing with %s, certificate SHA-256: %s", configs.KeystoreAlias, certificateFingerprint(signingCertificate))
+		fmt.Println()
+
+		for key, value := range signingEnvs(string(configs.KeystorePassword), string(keyPassword)) {
+			if err := os.Setenv(key, value); err != nil {
+				failf("Failed to set environment variable (%s): %s", key, err)
+			}
+		}
+		gradleArgs = append(gradleArgs, signingArgs(keystorePth, configs.KeystoreAlias)...)
+	}
+
+	var gradleOutput string
+	var gradleErr error
+	if tasks != "" {
+		log.Infof("Running gradle task...")
+		gradleOutput, gradleErr = runGradleTask(gradlePath, tasks, configs.GradleOptions, gradleArgs, buildRootAbs, configs.DeployDir)
+		if gradleErr != nil && configs.RetryFailedTests {
+			log.Warnf("Gradle task failed: %s", gradleErr)
+			gradleErr = retryFailedTests(gradlePath, tasks, gradleOutput, configs, gradleArgs, buildRootAbs, gradleStarted)
+		}
+	}
+	if cachePruning != nil {
+		cachePruning.removeInitScript()
+	}
+	if keystorePth != "" {
+		// The keystore is only needed by the Gradle build
+		removeKeystore(keystorePth)
+	}
+	if repairedGradlewPath != "" {
+		removeRepairedGradlew(repairedGradlewPath)
+	}
+
+	if tasks != "" {
+		var taskEvents []taskEvent
+		if taskEventsPth != "" {
+			if taskEvents, err = readTaskEvents(taskEventsPth); err != nil {
+				log.Warnf("Failed to read task events: %s", err)
+			}
+		}
+
+		fmt.Println()
+		log.Infof("Collecting task outcomes...")
+		if err := processTaskOutcomes(gradleOutput, taskEvents, configs.DeployDir); err != nil {
+			log.Warnf("Failed to collect task outcomes: %s", err)
+		}
+
+		if configs.ProfileTasks {
+			fmt.Println()
+			log.Infof("Profiling tasks...")
+			if err := processTaskProfile(taskEvents, configs.DeployDir, configs.ProfileTopTasks); err != nil {
+				log.Warnf("Failed to create task profile: %s", err)
+			}
+		}
+
+		fmt.Println()
+		log.Infof("Collecting deprecation warnings...")
+		if err := processDeprecationWarnings(gradleOutput, configs.DeployDir); err != nil {
+			log.Warnf("Failed to collect deprecation warnings: %s", err)
+		}
+
+		fmt.Println()
+		log.Infof("Checking configuration cache...")
+		if err := processConfigurationCacheResult(gradleOutput); err != nil {
+			log.Warnf("Failed to check configuration cache: %s", err)
+		}
+
+		if configs.RemoteBuildCacheURL != "" {
+			fmt.Println()
+			log.Infof("Checking remote build cache errors...")
+			if err := processRemoteBuildCacheErrors(gradleOutput); err != nil {
+				log.Warnf("Failed to check remote build cache errors: %s", err)
+			}
+		}
+
+		for _, report := range []struct {
+			name     string
+			patterns filePatterns
+			envKey   string
+		}{
+			{"problems", problemsReportPatterns, problemsReportEnvKey},
+			{"configuration cache", configurationCacheReportPatterns, configurationCacheReportEnvKey},
+		} {
+			fmt.Println()
+			log.Infof("Collecting %s reports...", report.name)
+			if err := exportGradleReports(buildRootAbs, configs.DeployDir, report.patterns, report.envKey, gradleStarted); err != nil {
+				log.Warnf("Failed to collect %s reports: %s", report.name, err)
+			}
+		}
+
+		if configs.AnalyzeCriticalPath {
+			fmt.Println()
+			log.Infof("Analyzing the critical path...")
+			if err := processCriticalPath(taskEvents, configs.DeployDir); err != nil {
+				log.Warnf("Failed to analyze the critical path: %s", err)
+			}
+		}
+	}
+
+	if configs.ShardCount > 1 {
+		if err := exportShardTestResults(buildRootAbs, configs.DeployDir, configs.ShardIndex, gradleStarted); err != nil {
+			log.Warnf("Failed to export test results: %s", err)
+		}
+	}
+
+	if gradleErr != nil {
+		failf("Gradle task failed: %s", gradleErr)
+	}
+
+	var coverageErr error
+	if configs.CollectCoverage {
+		fmt.Println()
+		log.Infof("Collecting coverage reports...")
+		coverageErr = processCoverage(configs, buildRootAbs)
+	}
+
+	if cachePruning != nil {
+		fmt.Println()
+		log.Infof("Pruning Gradle cache...")
+		if reclaimed, err := cachePruning.reclaimed(); err != nil {
+			log.Warnf("Failed to measure the reclaimed cache size: %s", err)
+		} else {
+			log.Donef("Gradle's cache cleanup reclaimed %s", formatBytes(reclaimed))
+		}
 	}
 
 	// Collecting caches
 	fmt.Println()
 	log.Infof("Collecting cache:")
-	if warning := cache.Collect(buildRootAbs, utilscache.Level(configs.CacheLevel)); warning != nil {
-		log.Warnf("%s", warning)
+	if err := collectGradleCache(buildRootAbs, gradleVersion, gradleUserHome, utilscache.Level(configs.CacheLevel)); err != nil {
+		log.Warnf("Failed to collect cache: %s", err)
+	}
+	if configs.ConfigurationCache != configurationCacheOff {
+		if err := collectConfigurationCache(buildRootAbs, utilscache.Level(configs.CacheLevel)); err != nil {
+			log.Warnf("Failed to collect configuration cache: %s", err)
+		}
+	}
+	if configs.LocalBuildCache {
+		if err := collectLocalBuildCache(configs.LocalBuildCacheDir, utilscache.Level(configs.CacheLevel)); err != nil {
+			log.Warnf("Failed to collect local build cache: %s", err)
+		}
+	}
+	if configs.CacheLevel != string(utilscache.LevelNone) {
+		fmt.Println()
+		log.Infof("Cache size:")
+		if err := processCacheSize(pathutil.UserHomeDir(), gradleUserHome, configs.CacheSizeBudgetMB); err != nil {
+			log.Warnf("%s", err)
+		}
 	}
 
 	// Move apk and aab files
@@ -200,6 +691,9 @@ func main() {
 	if err != nil {
 		failf("Failed to find APK or AAB files: %s", err)
 	}
+	if variant != nil {
+		appFiles = filterVariantArtifacts(buildRootAbs, appFiles, *variant)
+	}
 	if len(appFiles) == 0 {
 		log.Warnf("No file name matched app filters")
 	}
@@ -265,6 +759,22 @@ func main() {
 		}
 	}
 
+	if versionCode != 0 || configs.VersionName != "" {
+		log.Infof("Verifying app versions...")
+		if err := verifyArtifactVersions(append(copiedApkFiles, copiedAabFiles...), versionCode, configs.VersionName); err != nil {
+			failf("The version of the apps doesn't match the injected version:\n%s", err)
+		}
+		log.Donef("The apps have the injected version")
+	}
+
+	if signingCertificate != nil {
+		log.Infof("Verifying app signatures...")
+		if err := verifyArtifactSignatures(append(copiedApkFiles, copiedAabFiles...), signingCertificate); err != nil {
+			failf("The apps are not signed with the certificate of %s (SHA-256 %s):\n%s", configs.KeystoreAlias, certificateFingerprint(signingCertificate), err)
+		}
+		log.Donef("The apps are signed with the certificate of %s", configs.KeystoreAlias)
+	}
+
 	testApkFiles, err := findArtifacts(buildRootAbs,
 		filePatterns{
 			include: filterEmpty(strings.Split(configs.TestApkFileIncludeFilter, "\n")),
@@ -367,4 +877,8 @@ func main() {
 		}
 		log.Donef("The mapping path is now available in the Environment Variable: $BITRISE_MAPPING_PATH (value: %s)", lastCopiedMappingFile)
 	}
+
+	if coverageErr != nil {
+		failf("Coverage check failed: %s", coverageErr)
+	}
 }
@@ -78,6 +78,9 @@ func TestResolveGradlewPath(t *testing.T) {
 				} else {
 					require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
 					require.NoError(t, os.WriteFile(fullPath, []byte("#!/bin/bash"), 0755))
+					if filepath.Base(path) == "gradlew" {
+						createWrapperFiles(t, filepath.Dir(fullPath))
+					}
 				}
 			}
 
@@ -86,7 +89,7 @@ func TestResolveGradlewPath(t *testing.T) {
 				gradlewPath = filepath.Join(workDir, tt.expectedPath)
 			}
 
-			result, err := resolveGradlewPath(tt.buildRootDir, gradlewPath)
+			result, err := resolveGradlewPath(tt.buildRootDir, gradlewPath, true)
 
 			if tt.expectedErr {
 				require.Error(t, err)
@@ -95,7 +98,7 @@ func TestResolveGradlewPath(t *testing.T) {
 
 			require.NoError(t, err)
 			require.True(t, filepath.IsAbs(result), "result should be absolute path: %s", result)
-			
+
 			if tt.expectedPath != "" {
 				expectedAbs := filepath.Join(workDir, tt.expectedPath)
 				expectedResolved, err := filepath.EvalSymlinks(expectedAbs)
@@ -107,3 +110,101 @@ func TestResolveGradlewPath(t *testing.T) {
 		})
 	}
 }
+
+func createWrapperFiles(t *testing.T, dir string) {
+	wrapperDir := filepath.Join(dir, "gradle", "wrapper")
+	require.NoError(t, os.MkdirAll(wrapperDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(wrapperDir, "gradle-wrapper.jar"), []byte("jar"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(wrapperDir, "gradle-wrapper.properties"), []byte("distributionUrl=https\\://services.gradle.org/distributions/gradle-8.7-bin.zip\n"), 0644))
+}
+
+func TestValidateGradlew(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         string
+		withWrapper     bool
+		autoFix         bool
+		expectedContent string
+		expectRepaired  bool
+		expectedErr     string
+	}{
+		{
+			name:            "valid script",
+			content:         "#!/bin/sh\necho gradle\n",
+			withWrapper:     true,
+			autoFix:         true,
+			expectedContent: "#!/bin/sh\necho gradle\n",
+		},
+		{
+			name:            "CRLF line endings",
+			content:         "#!/bin/sh\r\necho gradle\r\n",
+			withWrapper:     true,
+			autoFix:         true,
+			expectedContent: "#!/bin/sh\necho gradle\n",
+			expectRepaired:  true,
+		},
+		{
+			name:            "byte order mark and no shebang",
+			content:         "\xEF\xBB\xBFecho gradle\n",
+			withWrapper:     true,
+			autoFix:         true,
+			expectedContent: "#!/bin/sh\necho gradle\n",
+			expectRepaired:  true,
+		},
+		{
+			name:        "CRLF line endings without auto fix",
+			content:     "#!/bin/sh\r\necho gradle\r\n",
+			withWrapper: true,
+			autoFix:     false,
+			expectedErr: "Windows (CRLF) line endings",
+		},
+		{
+			name:        "missing wrapper files",
+			content:     "#!/bin/sh\necho gradle\n",
+			withWrapper: false,
+			autoFix:     true,
+			expectedErr: "gradle/wrapper/gradle-wrapper.jar is missing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			gradlewPath := filepath.Join(dir, "gradlew")
+			require.NoError(t, os.WriteFile(gradlewPath, []byte(tt.content), 0755))
+			if tt.withWrapper {
+				createWrapperFiles(t, dir)
+			}
+
+			result, err := validateGradlew(gradlewPath, tt.autoFix)
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			if tt.expectRepaired {
+				require.NotEqual(t, gradlewPath, result)
+				require.FileExists(t, filepath.Join(filepath.Dir(result), "gradle", "wrapper", "gradle-wrapper.jar"))
+				require.FileExists(t, filepath.Join(filepath.Dir(result), "gradle", "wrapper", "gradle-wrapper.properties"))
+
+				original, err := os.ReadFile(gradlewPath)
+				require.NoError(t, err)
+				require.Equal(t, tt.content, string(original))
+			} else {
+				require.Equal(t, gradlewPath, result)
+			}
+
+			content, err := os.ReadFile(result)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedContent, string(content))
+
+			if tt.expectRepaired {
+				removeRepairedGradlew(result)
+				require.NoDirExists(t, filepath.Dir(result))
+				require.FileExists(t, gradlewPath)
+			}
+		})
+	}
+}
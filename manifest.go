@@ -0,0 +1,378 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	apkManifestPath = "AndroidManifest.xml"
+	aabManifestPath = "base/manifest/AndroidManifest.xml"
+
+	// Resource ids of the android:versionCode and android:versionName attributes.
+	versionCodeAttrID = 0x0101021b
+	versionNameAttrID = 0x0101021c
+
+	// Binary XML (AXML) chunk types and value types, see ResourceTypes.h of the Android framework.
+	axmlStringPoolType   = 0x0001
+	axmlResourceMapType  = 0x0180
+	axmlStartElementType = 0x0102
+	axmlUTF8Flag         = 1 << 8
+	axmlNoIndex          = 0xffffffff
+	axmlTypeString       = 0x03
+	axmlTypeIntDec       = 0x10
+	axmlTypeIntHex       = 0x11
+)
+
+var errInvalidManifest = errors.New("invalid manifest")
+
+type manifestVersion struct {
+	Code int
+	Name string
+}
+
+func readZipEntry(zipPth, name string) ([]byte, error) {
+	r, err := zip.OpenReader(zipPth)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			log.Warnf("Failed to close %s: %s", zipPth, err)
+		}
+	}()
+
+	for _, f := range r.File {
+		if f.Name != name {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(rc)
+		if closeErr := rc.Close(); err == nil {
+			err = closeErr
+		}
+		return content, err
+	}
+	return nil, fmt.Errorf("%s not found", name)
+}
+
+// artifactManifestVersion reads the version of the APK's binary XML manifest or the AAB's protobuf manifest.
+func artifactManifestVersion(pth string) (*manifestVersion, error) {
+	switch strings.ToLower(filepath.Ext(pth)) {
+	case ".apk":
+		content, err := readZipEntry(pth, apkManifestPath)
+		if err != nil {
+			return nil, err
+		}
+		return parseBinaryXMLManifestVersion(content)
+	case ".aab":
+		content, err := readZipEntry(pth, aabManifestPath)
+		if err != nil {
+			return nil, err
+		}
+		return parseProtoXMLManifestVersion(content)
+	default:
+		return nil, fmt.Errorf("unsupported artifact: %s", pth)
+	}
+}
+
+// parseAXMLStringPool decodes the strings of a binary XML string pool chunk.
+func parseAXMLStringPool(chunk []byte) ([]string, error) {
+	if len(chunk) < 28 {
+		return nil, errInvalidManifest
+	}
+	headerSize := int(binary.LittleEndian.Uint16(chunk[2:]))
+	count := int(binary.LittleEndian.Uint32(chunk[8:]))
+	flags := binary.LittleEndian.Uint32(chunk[16:])
+	stringsStart := int(binary.LittleEndian.Uint32(chunk[20:]))
+	if headerSize+4*count > len(chunk) || stringsStart > len(chunk) {
+		return nil, errInvalidManifest
+	}
+
+	strs := make([]string, count)
+	for i := range strs {
+		pos := stringsStart + int(binary.LittleEndian.Uint32(chunk[headerSize+4*i:]))
+		if pos+4 > len(chunk) {
+			return nil, errInvalidManifest
+		}
+
+		if flags&axmlUTF8Flag != 0 {
+			// The character count and the byte count, each 1 or 2 bytes long.
+			for j := 0; j < 2; j++ {
+				if chunk[pos]&0x80 != 0 {
+					pos++
+				}
+				pos++
+			}
+			end := pos
+			for end < len(chunk) && chunk[end] != 0 {
+				end++
+			}
+			strs[i] = string(chunk[pos:end])
+			continue
+		}
+
+		length := int(binary.LittleEndian.Uint16(chunk[pos:]))
+		pos += 2
+		if length&0x8000 != 0 {
+			length = (length&0x7fff)<<16 | int(binary.LittleEndian.Uint16(chunk[pos:]))
+			pos += 2
+		}
+		if pos+2*length > len(chunk) {
+			return nil, errInvalidManifest
+		}
+		units := make([]uint16, length)
+		for j := range units {
+			units[j] = binary.LittleEndian.Uint16(chunk[pos+2*j:])
+		}
+		strs[i] = string(utf16.Decode(units))
+	}
+	return strs, nil
+}
+
+// parseBinaryXMLManifestVersion reads the versionCode and versionName attributes of the manifest element of a binary XML (AXML) manifest.
+func parseBinaryXMLManifestVersion(content []byte) (*manifestVersion, error) {
+	if len(content) < 8 {
+		return nil, errInvalidManifest
+	}
+
+	var strs []string
+	var resourceIDs []uint32
+	str := func(i uint32) string {
+		if int(i) < len(strs) {
+			return strs[i]
+		}
+		return ""
+	}
+
+	for pos := int(binary.LittleEndian.Uint16(content[2:])); pos+8 <= len(content); {
+		chunkType := binary.LittleEndian.Uint16(content[pos:])
+		headerSize := int(binary.LittleEndian.Uint16(content[pos+2:]))
+		size := int(binary.LittleEndian.Uint32(content[pos+4:]))
+		if size < 8 || pos+size > len(content) {
+			return nil, errInvalidManifest
+		}
+		chunk := content[pos : pos+size]
+		pos += size
+
+		switch chunkType {
+		case axmlStringPoolType:
+			var err error
+			if strs, err = parseAXMLStringPool(chunk); err != nil {
+				return nil, err
+			}
+		case axmlResourceMapType:
+			for i := headerSize; i+4 <= len(chunk); i += 4 {
+				resourceIDs = append(resourceIDs, binary.LittleEndian.Uint32(chunk[i:]))
+			}
+		case axmlStartElementType:
+			if headerSize+20 > len(chunk) || str(binary.LittleEndian.Uint32(chunk[headerSize+4:])) != "manifest" {
+				return nil, errInvalidManifest
+			}
+
+			attrStart := headerSize + int(binary.LittleEndian.Uint16(chunk[headerSize+8:]))
+			attrSize := int(binary.LittleEndian.Uint16(chunk[headerSize+10:]))
+			attrCount := int(binary.LittleEndian.Uint16(chunk[headerSize+12:]))
+
+			var version manifestVersion
+			for i := 0; i < attrCount; i++ {
+				if attrSize < 20 || attrStart+(i+1)*attrSize > len(chunk) {
+					return nil, errInvalidManifest
+				}
+				attr := chunk[attrStart+i*attrSize:]
+				nameIdx := binary.LittleEndian.Uint32(attr[4:])
+				rawValue := binary.LittleEndian.Uint32(attr[8:])
+				dataType := attr[15]
+				data := binary.LittleEndian.Uint32(attr[16:])
+
+				var resourceID uint32
+				if int(nameIdx) < len(resourceIDs) {
+					resourceID = resourceIDs[nameIdx]
+				}
+
+				value := str(rawValue)
+				if rawValue == axmlNoIndex && dataType == axmlTypeString {
+					value = str(data)
+				}
+
+				switch {
+				case resourceID == versionCodeAttrID || resourceID == 0 && str(nameIdx) == "versionCode":
+					if dataType == axmlTypeIntDec || dataType == axmlTypeIntHex {
+						version.Code = int(int32(data))
+					} else {
+						version.Code, _ = strconv.Atoi(value)
+					}
+				case resourceID == versionNameAttrID || resourceID == 0 && str(nameIdx) == "versionName":
+					version.Name = value
+				}
+			}
+			return &version, nil
+		}
+	}
+
+	return nil, errInvalidManifest
+}
+
+// protoField is a field of a protobuf message: varint holds the value of varint fields, bytes the value of length-delimited ones.
+type protoField struct {
+	Number int
+	Varint uint64
+	Bytes  []byte
+}
+
+func parseProtoFields(b []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errInvalidManifest
+		}
+		b = b[n:]
+
+		field := protoField{Number: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			if field.Varint, n = binary.Uvarint(b); n <= 0 {
+				return nil, errInvalidManifest
+			}
+			b = b[n:]
+		case 1:
+			if len(b) < 8 {
+				return nil, errInvalidManifest
+			}
+			b = b[8:]
+		case 2:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return nil, errInvalidManifest
+			}
+			field.Bytes = b[n : n+int(length)]
+			b = b[n+int(length):]
+		case 5:
+			if len(b) < 4 {
+				return nil, errInvalidManifest
+			}
+			b = b[4:]
+		default:
+			return nil, errInvalidManifest
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// Field numbers of the aapt2 Resources.proto messages.
+const (
+	protoXMLNodeElement         = 1
+	protoXMLElementName         = 3
+	protoXMLElementAttribute    = 4
+	protoXMLAttributeName       = 2
+	protoXMLAttributeValue      = 3
+	protoXMLAttributeResourceID = 5
+	protoXMLAttributeItem       = 6
+	protoItemPrimitive          = 7
+	protoPrimitiveIntDecimal    = 6
+	protoPrimitiveIntHex        = 7
+)
+
+// protoCompiledInt returns the integer value of a compiled Item, if it is one.
+func protoCompiledInt(item []byte) (int, bool) {
+	itemFields, err := parseProtoFields(item)
+	if err != nil {
+		return 0, false
+	}
+	for _, itemField := range itemFields {
+		if itemField.Number != protoItemPrimitive {
+			continue
+		}
+		primFields, err := parseProtoFields(itemField.Bytes)
+		if err != nil {
+			return 0, false
+		}
+		for _, primField := range primFields {
+			if primField.Number == protoPrimitiveIntDecimal || primField.Number == protoPrimitiveIntHex {
+				return int(int32(primField.Varint)), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// parseProtoXMLManifestVersion reads the versionCode and versionName attributes of the manifest element
+// of an App Bundle's protobuf (aapt2 XmlNode) manifest.
+func parseProtoXMLManifestVersion(content []byte) (*manifestVersion, error) {
+	nodeFields, err := parseProtoFields(content)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, nodeField := range nodeFields {
+		if nodeField.Number != protoXMLNodeElement {
+			continue
+		}
+		elementFields, err := parseProtoFields(nodeField.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		var version manifestVersion
+		isManifest := false
+		for _, elementField := range elementFields {
+			switch elementField.Number {
+			case protoXMLElementName:
+				isManifest = string(elementField.Bytes) == "manifest"
+			case protoXMLElementAttribute:
+				attrFields, err := parseProtoFields(elementField.Bytes)
+				if err != nil {
+					return nil, err
+				}
+
+				var name, value string
+				var resourceID uint64
+				var item []byte
+				for _, attrField := range attrFields {
+					switch attrField.Number {
+					case protoXMLAttributeName:
+						name = string(attrField.Bytes)
+					case protoXMLAttributeValue:
+						value = string(attrField.Bytes)
+					case protoXMLAttributeResourceID:
+						resourceID = attrField.Varint
+					case protoXMLAttributeItem:
+						item = attrField.Bytes
+					}
+				}
+
+				switch {
+				case resourceID == versionCodeAttrID || resourceID == 0 && name == "versionCode":
+					if code, ok := protoCompiledInt(item); ok {
+						version.Code = code
+					} else {
+						version.Code, _ = strconv.Atoi(value)
+					}
+				case resourceID == versionNameAttrID || resourceID == 0 && name == "versionName":
+					version.Name = value
+				}
+			}
+		}
+
+		if isManifest {
+			return &version, nil
+		}
+	}
+
+	return nil, errInvalidManifest
+}
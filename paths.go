@@ -1,13 +1,148 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/bitrise-io/go-utils/log"
 	"github.com/bitrise-io/go-utils/v2/pathutil"
 )
 
-func resolveGradlewPath(buildRootDir, gradlewPath string) (string, error) {
+// projectNotFoundError means that build_root_directory points to a missing directory,
+// the project layout can be discovered in that case.
+type projectNotFoundError string
+
+func (e projectNotFoundError) Error() string {
+	return string(e)
+}
+
+const gradlewShebang = "#!/bin/sh\n"
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// gradlewProblems describes the issues of a gradlew script, which make running it fail with errors like `exec format error`.
+type gradlewProblems struct {
+	// Fixable problems can be repaired in a copy of the script.
+	Fixable []string
+	// Fatal problems can't be repaired by the Step.
+	Fatal []string
+}
+
+func (p gradlewProblems) empty() bool {
+	return len(p.Fixable) == 0 && len(p.Fatal) == 0
+}
+
+func checkGradlew(gradlewPath string, content []byte) gradlewProblems {
+	var problems gradlewProblems
+
+	if bytes.HasPrefix(content, utf8BOM) {
+		problems.Fixable = append(problems.Fixable, "it starts with a UTF-8 byte order mark")
+		content = content[len(utf8BOM):]
+	}
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		problems.Fixable = append(problems.Fixable, "it has no shebang (#!) line")
+	}
+	if bytes.Contains(content, []byte("\r\n")) {
+		problems.Fixable = append(problems.Fixable, "it has Windows (CRLF) line endings, add `gradlew text eol=lf` to .gitattributes")
+	}
+
+	for _, name := range []string{"gradle-wrapper.jar", "gradle-wrapper.properties"} {
+		pth := filepath.Join(filepath.Dir(gradlewPath), "gradle", "wrapper", name)
+		if _, err := os.Stat(pth); err != nil {
+			problems.Fatal = append(problems.Fatal, fmt.Sprintf("gradle/wrapper/%s is missing next to it (%s)", name, pth))
+		}
+	}
+
+	return problems
+}
+
+func repairGradlewContent(content []byte) []byte {
+	content = bytes.TrimPrefix(content, utf8BOM)
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		content = append([]byte(gradlewShebang), content...)
+	}
+	return content
+}
+
+// repairGradlew writes the fixed script into a temporary directory along with a copy of its gradle/wrapper directory,
+// as the wrapper looks for the jar next to the script. The repository is left untouched.
+func repairGradlew(gradlewPath string, content []byte) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "gradlew")
+	if err != nil {
+		return "", err
+	}
+
+	wrapperDir := filepath.Join(filepath.Dir(gradlewPath), "gradle", "wrapper")
+	tmpWrapperDir := filepath.Join(tmpDir, "gradle", "wrapper")
+	if err := os.MkdirAll(tmpWrapperDir, 0755); err != nil {
+		return "", err
+	}
+	for _, name := range []string{"gradle-wrapper.jar", "gradle-wrapper.properties"} {
+		wrapperFile, err := os.ReadFile(filepath.Join(wrapperDir, name))
+		if err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(tmpWrapperDir, name), wrapperFile, 0644); err != nil {
+			return "", err
+		}
+	}
+
+	repairedPath := filepath.Join(tmpDir, filepath.Base(gradlewPath))
+	if err := os.WriteFile(repairedPath, repairGradlewContent(content), 0755); err != nil {
+		return "", err
+	}
+
+	return repairedPath, nil
+}
+
+// removeRepairedGradlew removes the temporary directory of the repaired gradlew copy.
+func removeRepairedGradlew(repairedPath string) {
+	if err := os.RemoveAll(filepath.Dir(repairedPath)); err != nil {
+		log.Warnf("Failed to remove the repaired gradlew copy (%s): %s", repairedPath, err)
+	}
+}
+
+// validateGradlew checks the gradlew script and returns the path of the script to run:
+// the original one, or a repaired copy if autoFix is enabled and the script has fixable problems.
+func validateGradlew(gradlewPath string, autoFix bool) (string, error) {
+	content, err := os.ReadFile(gradlewPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read gradlew: %w", err)
+	}
+
+	problems := checkGradlew(gradlewPath, content)
+	if problems.empty() {
+		return gradlewPath, nil
+	}
+
+	if len(problems.Fatal) > 0 || !autoFix {
+		all := append(problems.Fatal, problems.Fixable...)
+		return "", fmt.Errorf("gradlew (%s) is broken: %s", gradlewPath, strings.Join(all, "; "))
+	}
+
+	repairedPath, err := repairGradlew(gradlewPath, content)
+	if err != nil {
+		return "", fmt.Errorf("failed to repair gradlew (%s): %w", gradlewPath, err)
+	}
+	log.Warnf("gradlew (%s) is broken: %s", gradlewPath, strings.Join(problems.Fixable, "; "))
+	log.Warnf("Using a repaired copy: %s", repairedPath)
+
+	return repairedPath, nil
+}
+
+// joinGradlewPath returns the absolute path of gradlew, gradlewPath is relative to the build root directory.
+func joinGradlewPath(buildRootAbs, gradlewPath string) string {
+	if filepath.IsAbs(gradlewPath) {
+		return gradlewPath
+	}
+	return filepath.Clean(filepath.Join(buildRootAbs, gradlewPath))
+}
+
+func resolveGradlewPath(buildRootDir, gradlewPath string, autoFix bool) (string, error) {
 	buildRootAbs, err := filepath.Abs(buildRootDir)
 	if err != nil {
 		return "", fmt.Errorf("can't get absolute path for build_root_directory (%s): %w", buildRootDir, err)
@@ -17,21 +152,16 @@ func resolveGradlewPath(buildRootDir, gradlewPath string) (string, error) {
 	if exist, err := pathChecker.IsPathExists(buildRootAbs); err != nil {
 		return "", fmt.Errorf("failed to check if build_root_directory exists at: %s: %w", buildRootAbs, err)
 	} else if !exist {
-		return "", fmt.Errorf("build_root_directory does not exist at: %s", buildRootAbs)
+		return "", projectNotFoundError(fmt.Sprintf("build_root_directory does not exist at: %s", buildRootAbs))
 	}
 
-	var resolvedGradlewPath string
-	if filepath.IsAbs(gradlewPath) {
-		resolvedGradlewPath = gradlewPath
-	} else {
-		resolvedGradlewPath = filepath.Clean(filepath.Join(buildRootAbs, gradlewPath))
-	}
+	resolvedGradlewPath := joinGradlewPath(buildRootAbs, gradlewPath)
 
 	if exist, err := pathChecker.IsPathExists(resolvedGradlewPath); err != nil {
 		return "", fmt.Errorf("failed to check if gradlew exists at: %s: %w", resolvedGradlewPath, err)
 	} else if !exist {
-		return "", fmt.Errorf("gradlew does not exist at: %s", resolvedGradlewPath)
+		return "", missingGradlewError(buildRootAbs, resolvedGradlewPath)
 	}
 
-	return resolvedGradlewPath, nil
+	return validateGradlew(resolvedGradlewPath, autoFix)
 }
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	traceFileName   = "trace.json"
+	traceEnvKey     = "BITRISE_GRADLE_TRACE_PATH"
+	defaultTopTasks = 10
+)
+
+// chromeTraceEvent is an event of the Chrome Trace Event Format,
+// which can be opened in chrome://tracing or https://ui.perfetto.dev.
+type chromeTraceEvent struct {
+	Name      string            `json:"name"`
+	Category  string            `json:"cat,omitempty"`
+	Phase     string            `json:"ph"`
+	Timestamp int64             `json:"ts"`
+	Duration  int64             `json:"dur,omitempty"`
+	PID       int               `json:"pid"`
+	TID       int               `json:"tid"`
+	Args      map[string]string `json:"args,omitempty"`
+}
+
+type chromeTrace struct {
+	TraceEvents     []chromeTraceEvent `json:"traceEvents"`
+	DisplayTimeUnit string             `json:"displayTimeUnit"`
+}
+
+func (e taskEvent) duration() time.Duration {
+	return time.Duration(e.End-e.Start) * time.Millisecond
+}
+
+// assignWorkers returns the worker (lane) index of each task event. Gradle's task events don't expose the
+// executing thread, so the workers are reconstructed from the task timings: each task goes to the first worker
+// which is idle at the task's start. This results in as many workers as the maximum number of parallel tasks.
+func assignWorkers(events []taskEvent) []int {
+	order := make([]int, len(events))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return events[order[i]].Start < events[order[j]].Start
+	})
+
+	workers := make([]int, len(events))
+	var busyUntil []int64
+	for _, idx := range order {
+		event := events[idx]
+
+		worker := -1
+		for w, end := range busyUntil {
+			if end <= event.Start {
+				worker = w
+				break
+			}
+		}
+		if worker == -1 {
+			worker = len(busyUntil)
+			busyUntil = append(busyUntil, 0)
+		}
+
+		busyUntil[worker] = event.End
+		workers[idx] = worker
+	}
+
+	return workers
+}
+
+func chromeTraceFromTaskEvents(events []taskEvent) chromeTrace {
+	trace := chromeTrace{DisplayTimeUnit: "ms"}
+	if len(events) == 0 {
+		return trace
+	}
+
+	buildStart := events[0].Start
+	for _, event := range events {
+		if event.Start < buildStart {
+			buildStart = event.Start
+		}
+	}
+
+	workers := assignWorkers(events)
+	workerCount := 0
+	for i, event := range events {
+		trace.TraceEvents = append(trace.TraceEvents, chromeTraceEvent{
+			Name:      event.Path,
+			Category:  event.Outcome,
+			Phase:     "X",
+			Timestamp: (event.Start - buildStart) * 1000,
+			Duration:  (event.End - event.Start) * 1000,
+			PID:       1,
+			TID:       workers[i],
+			Args:      map[string]string{"outcome": event.Outcome},
+		})
+		if workers[i]+1 > workerCount {
+			workerCount = workers[i] + 1
+		}
+	}
+
+	for w := 0; w < workerCount; w++ {
+		trace.TraceEvents = append(trace.TraceEvents, chromeTraceEvent{
+			Name:  "thread_name",
+			Phase: "M",
+			PID:   1,
+			TID:   w,
+			Args:  map[string]string{"name": fmt.Sprintf("worker %d", w+1)},
+		})
+	}
+
+	return trace
+}
+
+func slowestTasks(events []taskEvent, n int) []taskEvent {
+	sorted := make([]taskEvent, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].duration() > sorted[j].duration()
+	})
+
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+func processTaskProfile(events []taskEvent, deployDir string, topTasks int) error {
+	if len(events) == 0 {
+		log.Warnf("No task events recorded, skipping the task profile")
+		return nil
+	}
+
+	if topTasks <= 0 {
+		topTasks = defaultTopTasks
+	}
+
+	log.Printf("Slowest tasks:")
+	log.Printf("%-60s %-12s %10s", "Task", "Outcome", "Duration")
+	for _, event := range slowestTasks(events, topTasks) {
+		log.Printf("%-60s %-12s %9.1fs", event.Path, event.Outcome, event.duration().Seconds())
+	}
+
+	content, err := json.Marshal(chromeTraceFromTaskEvents(events))
+	if err != nil {
+		return err
+	}
+
+	tracePth := filepath.Join(deployDir, traceFileName)
+	if err := os.WriteFile(tracePth, content, 0644); err != nil {
+		return fmt.Errorf("failed to write trace: %w", err)
+	}
+
+	if err := exportEnvironmentWithEnvman(traceEnvKey, tracePth); err != nil {
+		return fmt.Errorf("failed to export environment (%s): %w", traceEnvKey, err)
+	}
+	log.Donef("The task trace is now available in the Environment Variable: $%s (value: %s)", traceEnvKey, tracePth)
+
+	return nil
+}
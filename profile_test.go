@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssignWorkers(t *testing.T) {
+	events := []taskEvent{
+		{Path: ":app:c", Start: 1500, End: 2500},
+		{Path: ":app:a", Start: 1000, End: 2000},
+		{Path: ":app:b", Start: 1000, End: 1500},
+		{Path: ":app:d", Start: 2000, End: 3000},
+	}
+
+	require.Equal(t, []int{1, 0, 1, 0}, assignWorkers(events))
+}
+
+func TestChromeTraceFromTaskEvents(t *testing.T) {
+	events := []taskEvent{
+		{Path: ":app:compileKotlin", Outcome: "EXECUTED", Start: 1000, End: 3000},
+		{Path: ":lib:compileKotlin", Outcome: "FROM-CACHE", Start: 1500, End: 1600},
+	}
+
+	trace := chromeTraceFromTaskEvents(events)
+	require.Equal(t, chromeTrace{
+		DisplayTimeUnit: "ms",
+		TraceEvents: []chromeTraceEvent{
+			{Name: ":app:compileKotlin", Category: "EXECUTED", Phase: "X", Timestamp: 0, Duration: 2000000, PID: 1, TID: 0, Args: map[string]string{"outcome": "EXECUTED"}},
+			{Name: ":lib:compileKotlin", Category: "FROM-CACHE", Phase: "X", Timestamp: 500000, Duration: 100000, PID: 1, TID: 1, Args: map[string]string{"outcome": "FROM-CACHE"}},
+			{Name: "thread_name", Phase: "M", PID: 1, TID: 0, Args: map[string]string{"name": "worker 1"}},
+			{Name: "thread_name", Phase: "M", PID: 1, TID: 1, Args: map[string]string{"name": "worker 2"}},
+		},
+	}, trace)
+}
+
+func TestSlowestTasks(t *testing.T) {
+	events := []taskEvent{
+		{Path: ":a", Start: 0, End: 100},
+		{Path: ":b", Start: 0, End: 300},
+		{Path: ":c", Start: 0, End: 200},
+	}
+
+	require.Equal(t, []taskEvent{events[1], events[2]}, slowestTasks(events, 2))
+	require.Len(t, slowestTasks(events, 10), 3)
+}
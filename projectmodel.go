@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	projectModelFileName = "gradle-project-model.json"
+	projectModelEnvKey   = "BITRISE_GRADLE_PROJECT_MODEL_PATH"
+
+	moduleTypeAndroidApplication    = "android-application"
+	moduleTypeAndroidLibrary        = "android-library"
+	moduleTypeAndroidDynamicFeature = "android-dynamic-feature"
+	moduleTypeAndroidTest           = "android-test"
+	moduleTypeKotlinMultiplatform   = "kotlin-multiplatform"
+	moduleTypeJVM                   = "jvm"
+	moduleTypeUnknown               = "unknown"
+)
+
+var (
+	// Line comments (not the // of URLs in strings, like "https://...") and block comments.
+	lineCommentRegexp  = regexp.MustCompile(`(?m)(^|\s)//.*$`)
+	blockCommentRegexp = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+	// Like: include ':app', ':core:data' or include(":app", ":core:data"), the arguments may span lines.
+	includeRegexp = regexp.MustCompile(`(?m)(?:^|[\s;])include\b\s*(?:\(([^)]*)\)|((?:[^\n]*,[ \t]*\n)*[^\n]*))`)
+	// Like: project(':app').projectDir = file('modules/app') or project(":app").projectDir = File(rootDir, "modules/app")
+	projectDirRegexp = regexp.MustCompile(`project\s*\(\s*["']([^"']+)["']\s*\)\s*\.projectDir\s*=\s*(?:new\s+)?(?:file|File)\s*\(\s*(?:(?:rootDir|settingsDir)\s*,\s*)?["']([^"']+)["']`)
+	// Like: rootProject.name = "my-app"
+	rootProjectNameRegexp = regexp.MustCompile(`rootProject\.name\s*=\s*["']([^"']+)["']`)
+	quotedStringRegexp    = regexp.MustCompile(`["']([^"']+)["']`)
+
+	pluginsBlockRegexp = regexp.MustCompile(`(?m)^\s*plugins\s*\{`)
+
+	// Plugin declarations of the plugins block, like: id 'com.android.application', id("com.android.library") version "8.2.0",
+	// kotlin("multiplatform"), alias(libs.plugins.android.application), `java-library` or java
+	pluginIDRegexp       = regexp.MustCompile(`^id\s*\(?\s*["']([^"']+)["']`)
+	pluginKotlinRegexp   = regexp.MustCompile(`^kotlin\s*\(\s*["']([^"']+)["']`)
+	pluginAliasRegexp    = regexp.MustCompile(`^alias\s*\(\s*([\w.]+)\s*\)`)
+	pluginBacktickRegexp = regexp.MustCompile("^`([\\w.-]+)`")
+	pluginBareRegexp     = regexp.MustCompile(`^([a-zA-Z]\w*)$`)
+	pluginApplyFalse     = regexp.MustCompile(`\bapply\s*\(?\s*false`)
+	// Like: apply plugin: 'com.android.application' or apply(plugin = "com.android.application")
+	applyPluginRegexp = regexp.MustCompile(`apply\s*\(?\s*plugin\s*[:=]\s*["']([^"']+)["']`)
+
+	// Version catalog plugins, like: android-application = { id = "com.android.application", version.ref = "agp" }
+	// or android-application = "com.android.application:8.2.0"
+	catalogPluginRegexp = regexp.MustCompile(`^([\w.-]+)\s*=\s*(?:\{.*\bid\s*=\s*"([^"]+)"|"([^":]+)(?::[^"]*)?")`)
+)
+
+// legacyPluginIDs maps the legacy ids of the Kotlin plugins to the ids of the plugins DSL.
+var legacyPluginIDs = map[string]string{
+	"kotlin":               "org.jetbrains.kotlin.jvm",
+	"kotlin-android":       "org.jetbrains.kotlin.android",
+	"kotlin-multiplatform": "org.jetbrains.kotlin.multiplatform",
+	"kotlin-kapt":          "org.jetbrains.kotlin.kapt",
+}
+
+// moduleTypePlugins decides the module type, the first matching type wins:
+// a Kotlin Multiplatform module with an Android target applies com.android.library too.
+var moduleTypePlugins = []struct {
+	moduleType string
+	pluginIDs  []string
+}{
+	{moduleTypeAndroidApplication, []string{"com.android.application"}},
+	{moduleTypeKotlinMultiplatform, []string{"org.jetbrains.kotlin.multiplatform"}},
+	{moduleTypeAndroidLibrary, []string{"com.android.library"}},
+	{moduleTypeAndroidDynamicFeature, []string{"com.android.dynamic-feature"}},
+	{moduleTypeAndroidTest, []string{"com.android.test"}},
+	{moduleTypeJVM, []string{"java", "java-library", "application", "groovy", "scala", "java-gradle-plugin", "kotlin-dsl", "org.jetbrains.kotlin.jvm"}},
+}
+
+type projectModule struct {
+	// Path is the Gradle project path, like :core:data.
+	Path string `json:"path"`
+	// Dir and BuildFile are relative to the root project, BuildFile is empty if the module has no build script.
+	Dir       string   `json:"dir"`
+	BuildFile string   `json:"build_file"`
+	Type      string   `json:"type"`
+	Plugins   []string `json:"plugins"`
+}
+
+type projectModel struct {
+	Name           string          `json:"name"`
+	SettingsFile   string          `json:"settings_file"`
+	Modules        []projectModule `json:"modules"`
+	IncludedBuilds []string        `json:"included_builds"`
+}
+
+func stripComments(content string) string {
+	content = blockCommentRegexp.ReplaceAllString(content, "")
+	return lineCommentRegexp.ReplaceAllString(content, "$1")
+}
+
+// normalizeProjectPath returns the absolute Gradle path of the project, like :app for app.
+func normalizeProjectPath(pth string) string {
+	return ":" + strings.TrimPrefix(pth, ":")
+}
+
+// parseSettingsIncludes returns the project paths included by the settings script, in declaration order.
+func parseSettingsIncludes(content string) []string {
+	var paths []string
+	seen := map[string]bool{}
+	for _, match := range includeRegexp.FindAllStringSubmatch(stripComments(content), -1) {
+		args := match[1] + match[2]
+		for _, quoted := range quotedStringRegexp.FindAllStringSubmatch(args, -1) {
+			pth := normalizeProjectPath(quoted[1])
+			if !seen[pth] {
+				seen[pth] = true
+				paths = append(paths, pth)
+			}
+		}
+	}
+	return paths
+}
+
+// parseProjectDirs returns the custom project directories set by the settings script, by project path.
+func parseProjectDirs(content string) map[string]string {
+	dirs := map[string]string{}
+	for _, match := range projectDirRegexp.FindAllStringSubmatch(stripComments(content), -1) {
+		dirs[normalizeProjectPath(match[1])] = filepath.Clean(match[2])
+	}
+	return dirs
+}
+
+func parseIncludedBuilds(content string) []string {
+	var builds []string
+	for _, match := range includeBuildRegexp.FindAllStringSubmatch(stripComments(content), -1) {
+		builds = append(builds, filepath.Clean(match[1]))
+	}
+	return builds
+}
+
+// catalogAccessor converts a version catalog alias to its accessor, like android-application to android.application.
+func catalogAccessor(alias string) string {
+	return strings.NewReplacer("-", ".", "_", ".").Replace(alias)
+}
+
+// parseCatalogPlugins returns the plugin ids of the [plugins] table of a version catalog, by accessor (like plugins.android.application).
+func parseCatalogPlugins(content string) map[string]string {
+	plugins := map[string]string{}
+	inPlugins := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inPlugins = line == "[plugins]"
+			continue
+		}
+		if !inPlugins {
+			continue
+		}
+
+		if match := catalogPluginRegexp.FindStringSubmatch(line); match != nil {
+			plugins["plugins."+catalogAccessor(strings.Trim(match[1], `"`))] = match[2] + match[3]
+		}
+	}
+	return plugins
+}
+
+// readCatalogPlugins reads the plugins of the version catalogs in the gradle directory,
+// keyed by the full accessor, like libs.plugins.android.application for gradle/libs.versions.toml.
+func readCatalogPlugins(rootDir string) map[string]string {
+	catalogPths, err := filepath.Glob(filepath.Join(rootDir, "gradle", "*.versions.toml"))
+	if err != nil {
+		return nil
+	}
+
+	plugins := map[string]string{}
+	for _, pth := range catalogPths {
+		content, err := os.ReadFile(pth)
+		if err != nil {
+			log.Warnf("Failed to read %s: %s", pth, err)
+			continue
+		}
+
+		catalogName := strings.TrimSuffix(filepath.Base(pth), ".versions.toml")
+		for accessor, id := range parseCatalogPlugins(string(content)) {
+			plugins[catalogName+"."+accessor] = id
+		}
+	}
+	return plugins
+}
+
+// pluginsBlocks returns the content of the plugins { ... } blocks of the build script.
+func pluginsBlocks(content string) []string {
+	var blocks []string
+	for _, loc := range pluginsBlockRegexp.FindAllStringIndex(content, -1) {
+		depth := 0
+		for i := loc[1] - 1; i < len(content); i++ {
+			if content[i] == '{' {
+				depth++
+			} else if content[i] == '}' {
+				depth--
+				if depth == 0 {
+					blocks = append(blocks, content[loc[1]:i])
+					break
+				}
+			}
+		}
+	}
+	return blocks
+}
+
+func normalizePluginID(id string) string {
+	if normalized, ok := legacyPluginIDs[id]; ok {
+		return normalized
+	}
+	return id
+}
+
+// parsePluginIDs returns the ids of the plugins applied by the build script, plugins declared with apply false are skipped.
+// The version catalog aliases are resolved with catalogPlugins, the unresolved ones are kept as they are.
+func parsePluginIDs(content string, catalogPlugins map[string]string) []string {
+	content = stripComments(content)
+
+	var ids []string
+	for _, block := range pluginsBlocks(content) {
+		for _, line := range strings.FieldsFunc(block, func(r rune) bool { return r == '\n' || r == ';' }) {
+			line = strings.TrimSpace(line)
+			if line == "" || pluginApplyFalse.MatchString(line) {
+				continue
+			}
+
+			if match := pluginIDRegexp.FindStringSubmatch(line); match != nil {
+				ids = append(ids, match[1])
+			} else if match := pluginKotlinRegexp.FindStringSubmatch(line); match != nil {
+				ids = append(ids, "org.jetbrains.kotlin."+match[1])
+			} else if match := pluginAliasRegexp.FindStringSubmatch(line); match != nil {
+				if id, ok := catalogPlugins[match[1]]; ok {
+					ids = append(ids, id)
+				} else {
+					ids = append(ids, match[1])
+				}
+			} else if match := pluginBacktickRegexp.FindStringSubmatch(line); match != nil {
+				ids = append(ids, match[1])
+			} else if match := pluginBareRegexp.FindStringSubmatch(line); match != nil {
+				ids = append(ids, match[1])
+			}
+		}
+	}
+
+	for _, match := range applyPluginRegexp.FindAllStringSubmatch(content, -1) {
+		ids = append(ids, match[1])
+	}
+
+	var plugins []string
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if id = normalizePluginID(id); !seen[id] {
+			seen[id] = true
+			plugins = append(plugins, id)
+		}
+	}
+	return plugins
+}
+
+func moduleType(plugins []string) string {
+	for _, typePlugins := range moduleTypePlugins {
+		for _, id := range typePlugins.pluginIDs {
+			if sliceContains(plugins, id) {
+				return typePlugins.moduleType
+			}
+		}
+	}
+	return moduleTypeUnknown
+}
+
+func sliceContains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func findBuildFile(rootDir, dir string) string {
+	for _, name := range []string{"build.gradle.kts", "build.gradle"} {
+		if pth := filepath.Join(dir, name); isFile(filepath.Join(rootDir, pth)) {
+			return pth
+		}
+	}
+	return ""
+}
+
+func parseModule(rootDir, pth, dir string, catalogPlugins map[string]string) projectModule {
+	module := projectModule{Path: pth, Dir: dir, BuildFile: findBuildFile(rootDir, dir), Plugins: []string{}}
+	if module.BuildFile != "" {
+		content, err := os.ReadFile(filepath.Join(rootDir, module.BuildFile))
+		if err != nil {
+			log.Warnf("Failed to read %s: %s", module.BuildFile, err)
+		} else if plugins := parsePluginIDs(string(content), catalogPlugins); plugins != nil {
+			module.Plugins = plugins
+		}
+	}
+	module.Type = moduleType(module.Plugins)
+	return module
+}
+
+// parseProjectModel reads the modules of the Gradle project from its settings and build scripts, without running Gradle:
+// includes built from code (like loops over directories) are not found.
+func parseProjectModel(rootDir string) (*projectModel, error) {
+	model := projectModel{Name: filepath.Base(rootDir), Modules: []projectModule{}, IncludedBuilds: []string{}}
+
+	var settings string
+	for _, name := range settingsFileNames {
+		content, err := os.ReadFile(filepath.Join(rootDir, name))
+		if err == nil {
+			model.SettingsFile = name
+			settings = string(content)
+			break
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+
+	if match := rootProjectNameRegexp.FindStringSubmatch(settings); match != nil {
+		model.Name = match[1]
+	}
+	if builds := parseIncludedBuilds(settings); builds != nil {
+		model.IncludedBuilds = builds
+	}
+
+	catalogPlugins := readCatalogPlugins(rootDir)
+	projectDirs := parseProjectDirs(settings)
+
+	model.Modules = append(model.Modules, parseModule(rootDir, ":", ".", catalogPlugins))
+	for _, pth := range parseSettingsIncludes(settings) {
+		dir, ok := projectDirs[pth]
+		if !ok {
+			dir = filepath.Join(strings.Split(strings.TrimPrefix(pth, ":"), ":")...)
+		}
+		model.Modules = append(model.Modules, parseModule(rootDir, pth, dir, catalogPlugins))
+	}
+
+	return &model, nil
+}
+
+func printProjectModel(model projectModel) {
+	modules := append([]projectModule{}, model.Modules...)
+	sort.SliceStable(modules, func(i, j int) bool {
+		return modules[i].Path < modules[j].Path
+	})
+
+	log.Printf("%s (%s), %d modules:", model.Name, model.SettingsFile, len(modules))
+	for _, module := range modules {
+		log.Printf("  %-40s %s", module.Path, module.Type)
+	}
+	for _, build := range model.IncludedBuilds {
+		log.Printf("  included build: %s", build)
+	}
+}
+
+func processProjectModel(buildRootAbs, deployDir string) error {
+	model, err := parseProjectModel(buildRootAbs)
+	if err != nil {
+		return err
+	}
+	printProjectModel(*model)
+
+	content, err := json.MarshalIndent(model, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	modelPth := filepath.Join(deployDir, projectModelFileName)
+	if err := os.WriteFile(modelPth, content, 0644); err != nil {
+		return fmt.Errorf("failed to write project model: %w", err)
+	}
+
+	if err := exportEnvironmentWithEnvman(projectModelEnvKey, modelPth); err != nil {
+		return fmt.Errorf("failed to export environment (%s): %w", projectModelEnvKey, err)
+	}
+	return nil
+}
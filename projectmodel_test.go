@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSettingsIncludes(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "Groovy",
+			content: "include ':app', ':core:data'\ninclude 'feature'\n",
+			want:    []string{":app", ":core:data", ":feature"},
+		},
+		{
+			name:    "Groovy, arguments spanning lines",
+			content: "include ':app',\n        ':core'\nrootProject.name = 'demo'\n",
+			want:    []string{":app", ":core"},
+		},
+		{
+			name:    "Kotlin",
+			content: "include(\":app\")\ninclude(\n    \":core:data\",\n    \":core:ui\",\n)\n",
+			want:    []string{":app", ":core:data", ":core:ui"},
+		},
+		{
+			name:    "comments, includeBuild and repository filters are skipped",
+			content: "// include ':old'\n/* include(\":older\") */\nincludeBuild(\"build-logic\")\ncontent { includeGroup(\"com.example\") }\ninclude(\":app\") // the app\n",
+			want:    []string{":app"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, parseSettingsIncludes(tt.content))
+		})
+	}
+}
+
+func TestParsePluginIDs(t *testing.T) {
+	catalogPlugins := parseCatalogPlugins(`[versions]
+agp = "8.2.0"
+
+[plugins]
+android-application = { id = "com.android.application", version.ref = "agp" }
+kotlin_multiplatform = "org.jetbrains.kotlin.multiplatform:1.9.22"
+
+[libraries]
+core = { module = "androidx.core:core-ktx", version = "1.12.0" }
+`)
+	require.Equal(t, map[string]string{
+		"plugins.android.application":  "com.android.application",
+		"plugins.kotlin.multiplatform": "org.jetbrains.kotlin.multiplatform",
+	}, catalogPlugins)
+
+	libsPlugins := map[string]string{}
+	for accessor, id := range catalogPlugins {
+		libsPlugins["libs."+accessor] = id
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "Groovy plugins block and apply",
+			content: "plugins {\n    id 'com.android.application'\n    id 'kotlin-android'\n}\napply plugin: 'kotlin-kapt'\n",
+			want:    []string{"com.android.application", "org.jetbrains.kotlin.android", "org.jetbrains.kotlin.kapt"},
+		},
+		{
+			name:    "Kotlin plugins block",
+			content: "plugins {\n    kotlin(\"multiplatform\")\n    id(\"com.android.library\") version \"8.2.0\"\n    `maven-publish`\n}\n",
+			want:    []string{"org.jetbrains.kotlin.multiplatform", "com.android.library", "maven-publish"},
+		},
+		{
+			name:    "version catalog aliases",
+			content: "plugins {\n    alias(libs.plugins.android.application)\n    alias(libs.plugins.unknown)\n}\n",
+			want:    []string{"com.android.application", "libs.plugins.unknown"},
+		},
+		{
+			name:    "apply false declarations are skipped",
+			content: "plugins {\n    alias(libs.plugins.android.application) apply false\n    id 'com.android.library' version '8.2.0' apply false\n    java\n}\n",
+			want:    []string{"java"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, parsePluginIDs(tt.content, libsPlugins))
+		})
+	}
+}
+
+func TestModuleType(t *testing.T) {
+	require.Equal(t, moduleTypeAndroidApplication, moduleType([]string{"org.jetbrains.kotlin.android", "com.android.application"}))
+	require.Equal(t, moduleTypeKotlinMultiplatform, moduleType([]string{"com.android.library", "org.jetbrains.kotlin.multiplatform"}))
+	require.Equal(t, moduleTypeAndroidLibrary, moduleType([]string{"com.android.library"}))
+	require.Equal(t, moduleTypeJVM, moduleType([]string{"org.jetbrains.kotlin.jvm"}))
+	require.Equal(t, moduleTypeUnknown, moduleType([]string{"myapp.android.feature"}))
+	require.Equal(t, moduleTypeUnknown, moduleType(nil))
+}
+
+func TestParseProjectModel(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"settings.gradle.kts": `rootProject.name = "demo"
+includeBuild("build-logic")
+include(":app", ":core:data", ":shared", ":server")
+project(":server").projectDir = file("backend/server")
+`,
+		"build.gradle.kts":             "plugins {\n    alias(libs.plugins.android.application) apply false\n}\n",
+		"gradle/libs.versions.toml":    "[plugins]\nandroid-application = { id = \"com.android.application\", version = \"8.2.0\" }\n",
+		"app/build.gradle.kts":         "plugins {\n    alias(libs.plugins.android.application)\n}\n",
+		"core/data/build.gradle":       "plugins {\n    id 'com.android.library'\n}\n",
+		"shared/build.gradle.kts":      "plugins {\n    kotlin(\"multiplatform\")\n    id(\"com.android.library\")\n}\n",
+		"backend/server/build.gradle":  "apply plugin: 'java'\n",
+		"build-logic/settings.gradle":  "",
+		"build-logic/build.gradle.kts": "plugins {\n    `kotlin-dsl`\n}\n",
+	}
+	for name, content := range files {
+		pth := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0755))
+		require.NoError(t, os.WriteFile(pth, []byte(content), 0644))
+	}
+
+	model, err := parseProjectModel(root)
+	require.NoError(t, err)
+	require.Equal(t, &projectModel{
+		Name:         "demo",
+		SettingsFile: "settings.gradle.kts",
+		Modules: []projectModule{
+			{Path: ":", Dir: ".", BuildFile: "build.gradle.kts", Type: moduleTypeUnknown, Plugins: []string{}},
+			{Path: ":app", Dir: "app", BuildFile: "app/build.gradle.kts", Type: moduleTypeAndroidApplication, Plugins: []string{"com.android.application"}},
+			{Path: ":core:data", Dir: "core/data", BuildFile: "core/data/build.gradle", Type: moduleTypeAndroidLibrary, Plugins: []string{"com.android.library"}},
+			{Path: ":shared", Dir: "shared", BuildFile: "shared/build.gradle.kts", Type: moduleTypeKotlinMultiplatform, Plugins: []string{"org.jetbrains.kotlin.multiplatform", "com.android.library"}},
+			{Path: ":server", Dir: "backend/server", BuildFile: "backend/server/build.gradle", Type: moduleTypeJVM, Plugins: []string{"java"}},
+		},
+		IncludedBuilds: []string{"build-logic"},
+	}, model)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const cacheCleanupInitScriptName = "bitrise-gradle-runner-cache-cleanup.gradle"
+
+// Gradle tracks the use of its cache entries itself (an up-to-date or cached task still marks the dependencies it resolved as used),
+// and removes the ones unused for removeUnusedEntriesAfterDays. Cleanup.ALWAYS runs the cleanup at the end of the build,
+// instead of at most once a day. The cache cleanup can be configured since Gradle 8.0, only by an init script in init.d of the Gradle user home.
+const cacheCleanupInitScriptTemplate = `if (GradleVersion.current().baseVersion >= GradleVersion.version('8.0')) {
+    beforeSettings { settings ->
+        settings.caches {
+            cleanup = Cleanup.ALWAYS
+            releasedWrappers.removeUnusedEntriesAfterDays = {{RETENTION_DAYS}}
+            snapshotWrappers.removeUnusedEntriesAfterDays = {{RETENTION_DAYS}}
+            downloadedResources.removeUnusedEntriesAfterDays = {{RETENTION_DAYS}}
+            createdResources.removeUnusedEntriesAfterDays = {{RETENTION_DAYS}}
+        }
+    }
+}
+`
+
+// gradleCachePruneGlobs are the entries of the Gradle user home cleaned up by Gradle, their size is reported before and after the build.
+var gradleCachePruneGlobs = []string{
+	// Like: caches/modules-2/files-2.1/com.squareup.okio/okio/3.2.0
+	"caches/modules-2/files-2.1/*/*/*",
+	// Like: caches/transforms-3/0a1b2c..., or (Gradle 8.8+) caches/8.8/transforms/0a1b2c...
+	"caches/transforms-*/*",
+	"caches/*/transforms/*",
+	// Like: wrapper/dists/gradle-8.2-bin
+	"wrapper/dists/*",
+}
+
+// gradleCachePruning holds the state recorded before the build to report the size Gradle's cache cleanup reclaimed.
+type gradleCachePruning struct {
+	gradleUserHome string
+	initScriptPth  string
+	sizeBefore     int64
+}
+
+func cacheCleanupInitScript(retentionDays int) string {
+	return strings.ReplaceAll(cacheCleanupInitScriptTemplate, "{{RETENTION_DAYS}}", fmt.Sprintf("%d", retentionDays))
+}
+
+func gradleCachePruneSize(gradleUserHome string) (int64, error) {
+	var patterns []string
+	for _, pattern := range gradleCachePruneGlobs {
+		patterns = append(patterns, filepath.Join(gradleUserHome, pattern))
+	}
+	return pathsSize(patterns...)
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(pth string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// prepareGradleCachePruning enables Gradle's cache cleanup for the build, it needs to be called before the build.
+func prepareGradleCachePruning(gradleUserHome string, retentionDays int) (*gradleCachePruning, error) {
+	sizeBefore, err := gradleCachePruneSize(gradleUserHome)
+	if err != nil {
+		return nil, err
+	}
+
+	initDir := filepath.Join(gradleUserHome, "init.d")
+	if err := os.MkdirAll(initDir, 0755); err != nil {
+		return nil, err
+	}
+	initScriptPth, err := writeInitScript(initDir, cacheCleanupInitScriptName, cacheCleanupInitScript(retentionDays))
+	if err != nil {
+		return nil, err
+	}
+
+	return &gradleCachePruning{gradleUserHome: gradleUserHome, initScriptPth: initScriptPth, sizeBefore: sizeBefore}, nil
+}
+
+// removeInitScript removes the cache cleanup init script, so that it doesn't affect the later builds using the Gradle user home.
+func (p gradleCachePruning) removeInitScript() {
+	if err := os.Remove(p.initScriptPth); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Warnf("Failed to remove %s: %s", p.initScriptPth, err)
+	}
+}
+
+// reclaimed returns the number of bytes Gradle's cache cleanup removed during the build.
+func (p gradleCachePruning) reclaimed() (int64, error) {
+	sizeAfter, err := gradleCachePruneSize(p.gradleUserHome)
+	if err != nil {
+		return 0, err
+	}
+	if sizeAfter > p.sizeBefore {
+		return 0, nil
+	}
+	return p.sizeBefore - sizeAfter, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGradleCachePruning(t *testing.T) {
+	gradleUserHome := t.TempDir()
+	files := map[string]string{
+		"used":            "caches/modules-2/files-2.1/com.squareup.okio/okio/3.2.0/abc/okio-3.2.0.jar",
+		"unused":          "caches/modules-2/files-2.1/com.squareup.okio/okio/2.10.0/def/okio-2.10.0.jar",
+		"current wrapper": "wrapper/dists/gradle-8.2-bin/xyz/gradle-8.2/lib/gradle-launcher-8.2.jar",
+		"not prunable":    "caches/jars-9/abc/buildSrc.jar",
+	}
+	for name, pth := range files {
+		writeTestFile(t, filepath.Join(gradleUserHome, pth), name)
+	}
+
+	pruning, err := prepareGradleCachePruning(gradleUserHome, 7)
+	require.NoError(t, err)
+
+	initScriptPth := filepath.Join(gradleUserHome, "init.d", cacheCleanupInitScriptName)
+	content, err := os.ReadFile(initScriptPth)
+	require.NoError(t, err)
+	require.Contains(t, string(content), "cleanup = Cleanup.ALWAYS")
+	require.Contains(t, string(content), "downloadedResources.removeUnusedEntriesAfterDays = 7")
+
+	// Gradle's cache cleanup during the build
+	require.NoError(t, os.RemoveAll(filepath.Join(gradleUserHome, "caches/modules-2/files-2.1/com.squareup.okio/okio/2.10.0")))
+
+	pruning.removeInitScript()
+	require.NoFileExists(t, initScriptPth)
+
+	reclaimed, err := pruning.reclaimed()
+	require.NoError(t, err)
+	require.Equal(t, int64(len("unused")), reclaimed)
+}
+
+func TestFormatBytes(t *testing.T) {
+	require.Equal(t, "512 B", formatBytes(512))
+	require.Equal(t, "1.5 KiB", formatBytes(1536))
+	require.Equal(t, "2.0 GiB", formatBytes(2*1024*1024*1024))
+}
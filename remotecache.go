@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	remoteBuildCacheInitScriptName = "remote-build-cache.init.gradle"
+
+	// The credentials are passed to Gradle in environment variables, so they never appear in the command or the init script.
+	remoteBuildCacheUsernameEnvKey = "BITRISE_GRADLE_REMOTE_BUILD_CACHE_USERNAME"
+	remoteBuildCachePasswordEnvKey = "BITRISE_GRADLE_REMOTE_BUILD_CACHE_PASSWORD"
+
+	remoteBuildCacheErrorsEnvKey = "BITRISE_GRADLE_REMOTE_BUILD_CACHE_ERRORS"
+
+	remoteBuildCachePreflightKey = "bitrise-gradle-runner-preflight"
+)
+
+const remoteBuildCacheInitScriptTemplate = `settingsEvaluated { settings ->
+    settings.buildCache {
+        remote(HttpBuildCache) { cache ->
+            cache.url = {{URL}}
+            cache.push = {{PUSH}}
+            if (cache.hasProperty('allowInsecureProtocol')) {
+                cache.allowInsecureProtocol = cache.url.scheme == 'http'
+            }
+
+            def bitriseUsername = System.getenv({{USERNAME_ENV}})
+            def bitrisePassword = System.getenv({{PASSWORD_ENV}})
+            if (bitriseUsername) {
+                cache.credentials { credentials ->
+                    credentials.username = bitriseUsername
+                    credentials.password = bitrisePassword
+                }
+            }
+        }
+    }
+}
+`
+
+// Like: Could not load entry 3f2a... for task ':app:compileKotlin' from remote build cache: Loading entry from 'https://cache.example.com/cache/3f2a...' response status 401: Unauthorized
+// or: The remote build cache was disabled during the build due to errors.
+var remoteBuildCacheErrorRegexp = regexp.MustCompile(`(?i)^(Could not (load|store) entry .* (from|in) remote build cache|Failed to (load|store) cache entry .*remote|The remote build cache was disabled)`)
+
+// Like: Loading entry from 'https://cache.example.com/cache/3f2a...' response status 401: Unauthorized
+var remoteBuildCacheEntryURLRegexp = regexp.MustCompile(`'[a-z]+://[^']*/[0-9a-f]{16,}'`)
+
+func remoteBuildCacheInitScript(url string, push bool) string {
+	return strings.NewReplacer(
+		"{{URL}}", groovyString(url),
+		"{{PUSH}}", strconv.FormatBool(push),
+		"{{USERNAME_ENV}}", groovyString(remoteBuildCacheUsernameEnvKey),
+		"{{PASSWORD_ENV}}", groovyString(remoteBuildCachePasswordEnvKey),
+	).Replace(remoteBuildCacheInitScriptTemplate)
+}
+
+// preflightRemoteBuildCache looks up a cache entry which doesn't exist, to find connection and authentication problems
+// before the build: the cache node is expected to respond with 404 (or 200).
+func preflightRemoteBuildCache(url, username, password string) error {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(url, "/")+"/"+remoteBuildCachePreflightKey, nil)
+	if err != nil {
+		return err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warnf("Failed to close response body: %s", err)
+		}
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNotFound:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("authentication failed: %s", resp.Status)
+	default:
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+}
+
+// parseRemoteBuildCacheErrors returns the deduplicated remote build cache errors of the Gradle output,
+// with the entry keys removed, so the same problem is reported once.
+func parseRemoteBuildCacheErrors(output string) []messageCount {
+	var cacheErrors []messageCount
+	indexByMessage := map[string]int{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !remoteBuildCacheErrorRegexp.MatchString(line) {
+			continue
+		}
+
+		message := remoteBuildCacheEntryURLRegexp.ReplaceAllString(line, "'<entry>'")
+		if i := strings.Index(message, "remote build cache: "); i != -1 {
+			message = message[i+len("remote build cache: "):]
+		}
+
+		if i, ok := indexByMessage[message]; ok {
+			cacheErrors[i].Count++
+			continue
+		}
+		indexByMessage[message] = len(cacheErrors)
+		cacheErrors = append(cacheErrors, messageCount{Message: message, Count: 1})
+	}
+	return cacheErrors
+}
+
+func processRemoteBuildCacheErrors(gradleOutput string) error {
+	cacheErrors := parseRemoteBuildCacheErrors(gradleOutput)
+	if len(cacheErrors) == 0 {
+		log.Printf("No remote build cache error found")
+	}
+
+	total := 0
+	for _, e := range cacheErrors {
+		log.Warnf("[remote build cache] %s (%dx)", e.Message, e.Count)
+		total += e.Count
+	}
+
+	if err := exportEnvironmentWithEnvman(remoteBuildCacheErrorsEnvKey, strconv.Itoa(total)); err != nil {
+		return fmt.Errorf("failed to export environment (%s): %w", remoteBuildCacheErrorsEnvKey, err)
+	}
+
+	return nil
+}
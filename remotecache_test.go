@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newBuildCacheNode is a stand-in for a Gradle HTTP build cache node, which has no entries.
+func newBuildCacheNode(t *testing.T, username, password string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != username || pass != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodGet || r.URL.Path != "/cache/"+remoteBuildCachePreflightKey {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPreflightRemoteBuildCache(t *testing.T) {
+	server := newBuildCacheNode(t, "ci", "secret")
+
+	require.NoError(t, preflightRemoteBuildCache(server.URL+"/cache/", "ci", "secret"))
+
+	err := preflightRemoteBuildCache(server.URL+"/cache/", "ci", "wrong")
+	require.EqualError(t, err, "authentication failed: 401 Unauthorized")
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	err = preflightRemoteBuildCache(unreachable.URL+"/cache/", "", "")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to connect")
+}
+
+func TestRemoteBuildCacheInitScript(t *testing.T) {
+	script := remoteBuildCacheInitScript("https://cache.example.com/cache/", true)
+	require.Contains(t, script, "cache.url = 'https://cache.example.com/cache/'\n")
+	require.Contains(t, script, "cache.push = true\n")
+	require.Contains(t, script, "System.getenv('"+remoteBuildCachePasswordEnvKey+"')")
+}
+
+func TestParseRemoteBuildCacheErrors(t *testing.T) {
+	output := `> Task :app:compileKotlin
+Could not load entry 3f2a6b1c9d8e7f60 for task ':app:compileKotlin' from remote build cache: Loading entry from 'https://cache.example.com/cache/3f2a6b1c9d8e7f60' response status 401: Unauthorized
+> Task :lib:compileKotlin
+Could not load entry 4a5b6c7d8e9f0a1b for task ':lib:compileKotlin' from remote build cache: Loading entry from 'https://cache.example.com/cache/4a5b6c7d8e9f0a1b' response status 401: Unauthorized
+Could not store entry 4a5b6c7d8e9f0a1b for task ':lib:compileKotlin' in remote build cache: Storing entry at 'https://cache.example.com/cache/4a5b6c7d8e9f0a1b' response status 413: Payload Too Large
+The remote build cache was disabled during the build due to errors.
+
+BUILD SUCCESSFUL in 12s
+`
+
+	require.Equal(t, []messageCount{
+		{Message: "Loading entry from '<entry>' response status 401: Unauthorized", Count: 2},
+		{Message: "Storing entry at '<entry>' response status 413: Payload Too Large", Count: 1},
+		{Message: "The remote build cache was disabled during the build due to errors.", Count: 1},
+	}, parseRemoteBuildCacheErrors(output))
+	require.Nil(t, parseRemoteBuildCacheErrors("BUILD SUCCESSFUL in 12s\n"))
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	problemsReportEnvKey           = "BITRISE_GRADLE_PROBLEMS_REPORT_PATH"
+	configurationCacheReportEnvKey = "BITRISE_GRADLE_CONFIGURATION_CACHE_REPORT_PATH"
+
+	reportDataBeginMarker = "// begin-report-data"
+	reportDataEndMarker   = "// end-report-data"
+)
+
+// Gradle 8.x HTML reports, like:
+// build/reports/problems/problems-report.html
+// build/reports/configuration-cache/<hash>/<hash>/configuration-cache-report.html
+var (
+	problemsReportPatterns = filePatterns{
+		include: []string{"*reports/problems/problems-report.html"},
+	}
+	configurationCacheReportPatterns = filePatterns{
+		include: []string{"*reports/configuration-cache/*configuration-cache-report.html"},
+	}
+)
+
+type reportFragment struct {
+	Text string `json:"text"`
+	Name string `json:"name"`
+}
+
+type reportDiagnostic struct {
+	Problem  []reportFragment `json:"problem"`
+	Input    []reportFragment `json:"input"`
+	Severity string           `json:"severity"`
+}
+
+type reportData struct {
+	Diagnostics       []reportDiagnostic `json:"diagnostics"`
+	TotalProblemCount int                `json:"totalProblemCount"`
+}
+
+type messageCount struct {
+	Message string
+	Count   int
+}
+
+type reportSummary struct {
+	Problems int
+	Inputs   int
+	// BySeverity is only filled for the problems report.
+	BySeverity map[string]int
+	Messages   []messageCount
+}
+
+func fragmentsText(fragments []reportFragment) string {
+	var b strings.Builder
+	for _, fragment := range fragments {
+		if fragment.Text != "" {
+			b.WriteString(fragment.Text)
+		} else if fragment.Name != "" {
+			b.WriteString("'" + fragment.Name + "'")
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// parseReportData extracts the JSON model embedded into the Gradle HTML reports between the report data markers.
+func parseReportData(html string) (reportData, error) {
+	var data reportData
+
+	begin := strings.Index(html, reportDataBeginMarker)
+	end := strings.Index(html, reportDataEndMarker)
+	if begin == -1 || end == -1 || end < begin {
+		return data, fmt.Errorf("no report data found")
+	}
+
+	content := html[begin+len(reportDataBeginMarker) : end]
+	if err := json.Unmarshal([]byte(content), &data); err != nil {
+		return data, fmt.Errorf("failed to parse report data: %w", err)
+	}
+
+	return data, nil
+}
+
+func summarizeReportData(data reportData) reportSummary {
+	summary := reportSummary{BySeverity: map[string]int{}}
+	counts := map[string]int{}
+	for _, diagnostic := range data.Diagnostics {
+		var message string
+		if len(diagnostic.Input) > 0 {
+			summary.Inputs++
+			message = "input: " + fragmentsText(diagnostic.Input)
+		} else {
+			summary.Problems++
+			message = fragmentsText(diagnostic.Problem)
+		}
+
+		if diagnostic.Severity != "" {
+			summary.BySeverity[diagnostic.Severity]++
+		}
+		counts[message]++
+	}
+
+	// The report might not include every problem, when there are too many of them.
+	if data.TotalProblemCount > summary.Problems {
+		summary.Problems = data.TotalProblemCount
+	}
+
+	for message, count := range counts {
+		summary.Messages = append(summary.Messages, messageCount{Message: message, Count: count})
+	}
+	sort.Slice(summary.Messages, func(i, j int) bool {
+		if summary.Messages[i].Count != summary.Messages[j].Count {
+			return summary.Messages[i].Count > summary.Messages[j].Count
+		}
+		return summary.Messages[i].Message < summary.Messages[j].Message
+	})
+
+	return summary
+}
+
+func printReportSummary(summary reportSummary) {
+	log.Printf("%d problem(s), %d build configuration input(s)", summary.Problems, summary.Inputs)
+
+	var severities []string
+	for severity := range summary.BySeverity {
+		severities = append(severities, severity)
+	}
+	sort.Strings(severities)
+	for _, severity := range severities {
+		log.Printf("  %s: %d", severity, summary.BySeverity[severity])
+	}
+
+	for _, message := range summary.Messages {
+		log.Printf("  %4dx %s", message.Count, message.Message)
+	}
+}
+
+// findGradleReports returns the reports written since gradleStarted.
+func findGradleReports(buildRootAbs string, patterns filePatterns, gradleStarted time.Time) ([]string, error) {
+	reports, err := findArtifacts(buildRootAbs, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	var fresh []string
+	for _, report := range reports {
+		fi, err := os.Lstat(report)
+		if err != nil {
+			return nil, err
+		}
+		if fi.ModTime().Before(gradleStarted) {
+			continue
+		}
+		fresh = append(fresh, report)
+	}
+
+	return fresh, nil
+}
+
+// exportGradleReports copies the reports written since gradleStarted into the deploy dir,
+// prints their summary and exports the path of the last one.
+func exportGradleReports(buildRootAbs, deployDir string, patterns filePatterns, envKey string, gradleStarted time.Time) error {
+	reports, err := findGradleReports(buildRootAbs, patterns, gradleStarted)
+	if err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		log.Printf("No report found")
+		return nil
+	}
+
+	lastCopiedReport := ""
+	for _, report := range reports {
+		ext := filepath.Ext(report)
+		baseName := strings.TrimSuffix(filepath.Base(report), ext)
+		deployPth, err := findDeployPth(deployDir, baseName, ext)
+		if err != nil {
+			return fmt.Errorf("failed to create deploy path for %s: %w", report, err)
+		}
+
+		log.Printf("Copying %s --> %s", report, deployPth)
+		if err := command.CopyFile(report, deployPth); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", report, err)
+		}
+		lastCopiedReport = deployPth
+
+		content, err := os.ReadFile(report)
+		if err != nil {
+			return err
+		}
+		data, err := parseReportData(string(content))
+		if err != nil {
+			log.Warnf("Failed to parse %s: %s", report, err)
+			continue
+		}
+		printReportSummary(summarizeReportData(data))
+	}
+
+	if err := exportEnvironmentWithEnvman(envKey, lastCopiedReport); err != nil {
+		return fmt.Errorf("failed to export environment (%s): %w", envKey, err)
+	}
+	log.Donef("The report path is now available in the Environment Variable: $%s (value: %s)", envKey, lastCopiedReport)
+
+	return nil
+}
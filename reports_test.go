@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const configurationCacheReportContent = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<script type="text/javascript">
+function configurationCacheProblems() { return (
+// begin-report-data
+{"diagnostics":[
+{"trace":[{"kind":"Task","path":":app:compileKotlin","type":"org.jetbrains.kotlin.gradle.tasks.KotlinCompile"}],"problem":[{"text":"invocation of "},{"name":"Task.project"},{"text":" at execution time is unsupported."}]},
+{"trace":[{"kind":"Task","path":":lib:compileKotlin","type":"org.jetbrains.kotlin.gradle.tasks.KotlinCompile"}],"problem":[{"text":"invocation of "},{"name":"Task.project"},{"text":" at execution time is unsupported."}]},
+{"trace":[{"kind":"BuildLogic","location":"build file 'app/build.gradle'"}],"input":[{"text":"system property "},{"name":"user.home"}]}
+],"totalProblemCount":2,"buildName":"sample","requestedTasks":"assembleDebug","cacheAction":"storing"}
+// end-report-data
+);}
+</script>
+</head>
+</html>
+`
+
+func TestParseReportData(t *testing.T) {
+	data, err := parseReportData(configurationCacheReportContent)
+	require.NoError(t, err)
+	require.Equal(t, 2, data.TotalProblemCount)
+	require.Equal(t, 3, len(data.Diagnostics))
+
+	require.Equal(t, reportSummary{
+		Problems:   2,
+		Inputs:     1,
+		BySeverity: map[string]int{},
+		Messages: []messageCount{
+			{Message: "invocation of 'Task.project' at execution time is unsupported.", Count: 2},
+			{Message: "input: system property 'user.home'", Count: 1},
+		},
+	}, summarizeReportData(data))
+
+	_, err = parseReportData("<html></html>")
+	require.Error(t, err)
+}
+
+func TestSummarizeProblemsReport(t *testing.T) {
+	data := reportData{
+		Diagnostics: []reportDiagnostic{
+			{Problem: []reportFragment{{Text: "Declaring client module dependencies has been deprecated."}}, Severity: "WARNING"},
+			{Problem: []reportFragment{{Text: "Declaring client module dependencies has been deprecated."}}, Severity: "WARNING"},
+			{Problem: []reportFragment{{Text: "Could not resolve plugin."}}, Severity: "ERROR"},
+		},
+		TotalProblemCount: 5,
+	}
+
+	summary := summarizeReportData(data)
+	require.Equal(t, 5, summary.Problems)
+	require.Equal(t, map[string]int{"WARNING": 2, "ERROR": 1}, summary.BySeverity)
+	require.Equal(t, messageCount{Message: "Declaring client module dependencies has been deprecated.", Count: 2}, summary.Messages[0])
+}
+
+func TestFindGradleReports(t *testing.T) {
+	buildRoot := t.TempDir()
+	started := time.Now().Add(-time.Minute)
+
+	reportPth := filepath.Join(buildRoot, "build", "reports", "configuration-cache", "abc", "def", "configuration-cache-report.html")
+	writeTestFile(t, reportPth, configurationCacheReportContent)
+
+	staleReportPth := filepath.Join(buildRoot, "lib", "build", "reports", "configuration-cache", "abc", "def", "configuration-cache-report.html")
+	writeTestFile(t, staleReportPth, configurationCacheReportContent)
+	stale := started.Add(-time.Hour)
+	require.NoError(t, os.Chtimes(staleReportPth, stale, stale))
+
+	problemsReportPth := filepath.Join(buildRoot, "build", "reports", "problems", "problems-report.html")
+	writeTestFile(t, problemsReportPth, "<html></html>")
+
+	reports, err := findGradleReports(buildRoot, configurationCacheReportPatterns, started)
+	require.NoError(t, err)
+	require.Equal(t, []string{reportPth}, reports)
+
+	reports, err = findGradleReports(buildRoot, problemsReportPatterns, started)
+	require.NoError(t, err)
+	require.Equal(t, []string{problemsReportPth}, reports)
+}
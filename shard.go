@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/kballard/go-shellquote"
+)
+
+const testResultsShardDirEnvKey = "BITRISE_TEST_RESULTS_SHARD_DIR"
+
+var packageDeclarationRegexp = regexp.MustCompile(`^\s*package\s+([\w.]+)`)
+
+type testClass struct {
+	Module string
+	Name   string
+}
+
+type testShard struct {
+	Classes  []testClass
+	Duration float64
+}
+
+func isTestSourceSetDir(name string) bool {
+	// Unit test source sets, like: src/test, src/testDebug, src/testFreeRelease
+	return strings.HasPrefix(name, "test") && name != "testFixtures"
+}
+
+func isTestClassFile(name string) bool {
+	ext := filepath.Ext(name)
+	if ext != ".java" && ext != ".kt" {
+		return false
+	}
+
+	base := strings.TrimSuffix(name, ext)
+	return strings.HasSuffix(base, "Test") || strings.HasSuffix(base, "Tests") || strings.HasPrefix(base, "Test")
+}
+
+func sourcePackage(pth string) (string, error) {
+	f, err := os.Open(pth)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Warnf("Failed to close %s: %s", pth, err)
+		}
+	}()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if match := packageDeclarationRegexp.FindStringSubmatch(scanner.Text()); match != nil {
+			return match[1], nil
+		}
+	}
+
+	return "", scanner.Err()
+}
+
+// findTestClasses statically collects the unit test classes of the project's modules,
+// based on the test source sets (like app/src/test/java or lib/src/testDebug/kotlin).
+func findTestClasses(buildRootDir string) ([]testClass, error) {
+	var classes []testClass
+	err := filepath.Walk(buildRootDir, func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			name := info.Name()
+			if pth != buildRootDir && (name == "build" || name == "node_modules" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !isTestClassFile(info.Name()) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(buildRootDir, pth)
+		if err != nil {
+			return nil
+		}
+
+		components := strings.Split(filepath.ToSlash(relPath), "/")
+		srcIdx := -1
+		for i := len(components) - 3; i >= 0; i-- {
+			if components[i] == "src" && isTestSourceSetDir(components[i+1]) && (components[i+2] == "java" || components[i+2] == "kotlin") {
+				srcIdx = i
+				break
+			}
+		}
+		if srcIdx == -1 {
+			return nil
+		}
+
+		pkg, err := sourcePackage(pth)
+		if err != nil {
+			log.Warnf("Failed to read package of %s: %s", pth, err)
+			return nil
+		}
+
+		name := strings.TrimSuffix(info.Name(), filepath.Ext(info.Name()))
+		if pkg != "" {
+			name = pkg + "." + name
+		}
+
+		classes = append(classes, testClass{
+			Module: ":" + strings.Join(components[:srcIdx], ":"),
+			Name:   name,
+		})
+
+		return nil
+	})
+
+	return classes, err
+}
+
+// readTestTimings returns the duration of the test classes (in seconds) from the JUnit XML reports
+// found at the given path, which can be a single report or a directory of reports.
+func readTestTimings(pth string) (map[string]float64, error) {
+	info, err := os.Stat(pth)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := []string{pth}
+	if info.IsDir() {
+		reports, err = findArtifacts(pth, filePatterns{include: []string{"*.xml"}})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	timings := map[string]float64{}
+	for _, report := range reports {
+		suite, err := parseJUnitResult(report)
+		if err != nil {
+			log.Warnf("%s", err)
+			continue
+		}
+
+		if suite.Time > 0 {
+			timings[suite.Name] += suite.Time
+			continue
+		}
+		for _, tc := range suite.TestCases {
+			timings[tc.ClassName] += tc.Time
+		}
+	}
+
+	return timings, nil
+}
+
+// splitTestClasses distributes the test classes across shardCount shards, using the longest processing time first
+// bin-packing: the classes are sorted by their duration and each is assigned to the least loaded shard.
+// Classes without timing data count with the average duration, or if there is no timing data at all, with the same weight.
+func splitTestClasses(classes []testClass, timings map[string]float64, shardCount int) []testShard {
+	var known, total float64
+	for _, class := range classes {
+		if d, ok := timings[class.Name]; ok {
+			known++
+			total += d
+		}
+	}
+	defaultDuration := 1.0
+	if known > 0 && total > 0 {
+		defaultDuration = total / known
+	}
+
+	duration := func(class testClass) float64 {
+		if d, ok := timings[class.Name]; ok {
+			return d
+		}
+		return defaultDuration
+	}
+
+	sorted := make([]testClass, len(classes))
+	copy(sorted, classes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		di, dj := duration(sorted[i]), duration(sorted[j])
+		if di != dj {
+			return di > dj
+		}
+		if sorted[i].Module != sorted[j].Module {
+			return sorted[i].Module < sorted[j].Module
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	shards := make([]testShard, shardCount)
+	for _, class := range sorted {
+		target := 0
+		for i := range shards {
+			if shards[i].Duration < shards[target].Duration {
+				target = i
+			}
+		}
+		shards[target].Classes = append(shards[target].Classes, class)
+		shards[target].Duration += duration(class)
+	}
+
+	return shards
+}
+
+// isTestTask reports whether the task is a unit test task, like test or testDebugUnitTest.
+func isTestTask(task string) bool {
+	return task == "test" || strings.HasPrefix(task, "test") && strings.HasSuffix(task, "Test")
+}
+
+// shardTasks runs the unqualified test tasks (like testDebugUnitTest) with the given test classes in their modules,
+// like: `:app:testDebugUnitTest --tests io.bitrise.MainTest :lib:testDebugUnitTest --tests io.bitrise.LibTest`.
+// The other tasks, the tasks which already have a project path and the options (and their values, like -x lint) are kept.
+func shardTasks(gradleTasks string, classes []testClass) (string, error) {
+	args, err := shellquote.Split(gradleTasks)
+	if err != nil {
+		return "", err
+	}
+
+	classesByModule := map[string][]string{}
+	for _, class := range classes {
+		classesByModule[class.Module] = append(classesByModule[class.Module], class.Name)
+	}
+
+	var modules []string
+	for module := range classesByModule {
+		modules = append(modules, module)
+		sort.Strings(classesByModule[module])
+	}
+	sort.Strings(modules)
+
+	var sharded []string
+	hasTask := false
+	for i, arg := range args {
+		isOptionValue := i > 0 && (args[i-1] == "-x" || args[i-1] == "--exclude-task" || args[i-1] == "--tests")
+		switch {
+		case strings.HasPrefix(arg, "-") || isOptionValue:
+			sharded = append(sharded, arg)
+		case strings.Contains(arg, ":") || !isTestTask(arg):
+			sharded = append(sharded, arg)
+			hasTask = true
+		default:
+			for _, module := range modules {
+				sharded = append(sharded, gradleTaskPath(module, arg))
+				for _, name := range classesByModule[module] {
+					sharded = append(sharded, "--tests", name)
+				}
+				hasTask = true
+			}
+		}
+	}
+
+	if !hasTask {
+		return "", nil
+	}
+	return shellquote.Join(sharded...), nil
+}
+
+// testShardTasks splits the test classes of the unqualified test tasks of gradleTasks, and returns the tasks of the configured shard.
+func testShardTasks(configs Config, buildRootAbs, gradleTasks string) (string, error) {
+	if configs.ShardIndex < 0 || configs.ShardIndex >= configs.ShardCount {
+		return "", fmt.Errorf("shard_index (%d) should be between 0 and shard_count - 1 (%d)", configs.ShardIndex, configs.ShardCount-1)
+	}
+
+	classes, err := findTestClasses(buildRootAbs)
+	if err != nil {
+		return "", fmt.Errorf("failed to find test classes: %w", err)
+	}
+
+	timings := map[string]float64{}
+	if configs.TestTimingsPath != "" {
+		timings, err = readTestTimings(configs.TestTimingsPath)
+		if err != nil {
+			log.Warnf("Failed to read test timings, splitting by test class count: %s", err)
+			timings = map[string]float64{}
+		}
+	}
+	if len(timings) == 0 {
+		log.Printf("No test timing data available, splitting by test class count")
+	}
+
+	shards := splitTestClasses(classes, timings, configs.ShardCount)
+	for i, shard := range shards {
+		log.Printf("Shard %d: %d test classes, estimated duration: %.1fs", i, len(shard.Classes), shard.Duration)
+	}
+
+	return shardTasks(gradleTasks, shards[configs.ShardIndex].Classes)
+}
+
+// exportShardTestResults copies the JUnit XML reports written since gradleStarted into a single directory,
+// prefixing the file names with the module and task, so that the results of all shards can be merged.
+func exportShardTestResults(buildRootAbs, deployDir string, shardIndex int, gradleStarted time.Time) error {
+	reports, err := findArtifacts(buildRootAbs, junitResultPatterns)
+	if err != nil {
+		return err
+	}
+
+	resultsDir := filepath.Join(deployDir, fmt.Sprintf("test-results-shard-%d", shardIndex))
+	if err := os.MkdirAll(resultsDir, 0755); err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		fi, err := os.Stat(report)
+		if err != nil {
+			return err
+		}
+		if fi.ModTime().Before(gradleStarted) {
+			continue
+		}
+
+		module := strings.Trim(strings.ReplaceAll(gradleModuleForPath(buildRootAbs, report), ":", "-"), "-")
+		task := filepath.Base(filepath.Dir(report))
+		name := strings.TrimPrefix(strings.Join([]string{module, task, filepath.Base(report)}, "__"), "__")
+
+		if err := command.CopyFile(report, filepath.Join(resultsDir, name)); err != nil {
+			return err
+		}
+	}
+
+	if err := exportEnvironmentWithEnvman(testResultsShardDirEnvKey, resultsDir); err != nil {
+		return fmt.Errorf("failed to export environment (%s): %w", testResultsShardDirEnvKey, err)
+	}
+	log.Donef("The test results are now available in the Environment Variable: $%s (value: %s)", testResultsShardDirEnvKey, resultsDir)
+
+	return nil
+}
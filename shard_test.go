@@ -0,0 +1,113 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindTestClasses(t *testing.T) {
+	buildRootDir := t.TempDir()
+	for pth, content := range map[string]string{
+		"app/src/test/java/io/bitrise/app/MainTest.java":            "package io.bitrise.app;\n\nclass MainTest {}",
+		"app/src/testDebug/kotlin/io/bitrise/app/DebugTests.kt":     "// header\npackage io.bitrise.app.debug\n\nclass DebugTests",
+		"app/src/test/java/io/bitrise/app/TestUtils.java":           "package io.bitrise.app;",
+		"app/src/test/java/io/bitrise/app/Fixtures.java":            "package io.bitrise.app;",
+		"app/src/androidTest/java/io/bitrise/app/ActivityTest.java": "package io.bitrise.app;",
+		"app/src/main/java/io/bitrise/app/Main.java":                "package io.bitrise.app;",
+		"app/build/generated/src/test/java/GeneratedTest.java":      "package generated;",
+		"feature/login/src/test/kotlin/LoginTest.kt":                "class LoginTest",
+		"src/test/java/io/bitrise/RootTest.java":                    "package io.bitrise;",
+	} {
+		writeTestFile(t, filepath.Join(buildRootDir, pth), content)
+	}
+
+	got, err := findTestClasses(buildRootDir)
+	require.NoError(t, err)
+	sort.Slice(got, func(i, j int) bool { return got[i].Name < got[j].Name })
+
+	require.Equal(t, []testClass{
+		{Module: ":feature:login", Name: "LoginTest"},
+		{Module: ":", Name: "io.bitrise.RootTest"},
+		{Module: ":app", Name: "io.bitrise.app.MainTest"},
+		{Module: ":app", Name: "io.bitrise.app.TestUtils"},
+		{Module: ":app", Name: "io.bitrise.app.debug.DebugTests"},
+	}, got)
+}
+
+func TestReadTestTimings(t *testing.T) {
+	timingsDir := t.TempDir()
+	writeTestFile(t, filepath.Join(timingsDir, "shard-0", "app__test__TEST-io.bitrise.sample.CalculatorTest.xml"), junitResultContent)
+	writeTestFile(t, filepath.Join(timingsDir, "shard-1", "TEST-io.bitrise.NoSuiteTimeTest.xml"), `<testsuite name="io.bitrise.NoSuiteTimeTest">
+  <testcase name="a" classname="io.bitrise.NoSuiteTimeTest" time="1.5"/>
+  <testcase name="b" classname="io.bitrise.NoSuiteTimeTest" time="2"/>
+</testsuite>`)
+
+	got, err := readTestTimings(timingsDir)
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{
+		"io.bitrise.sample.CalculatorTest": 0.5,
+		"io.bitrise.NoSuiteTimeTest":       3.5,
+	}, got)
+}
+
+func TestSplitTestClasses(t *testing.T) {
+	classes := []testClass{
+		{Module: ":app", Name: "A"},
+		{Module: ":app", Name: "B"},
+		{Module: ":app", Name: "C"},
+		{Module: ":lib", Name: "D"},
+		{Module: ":lib", Name: "E"},
+	}
+
+	t.Run("without timings", func(t *testing.T) {
+		shards := splitTestClasses(classes, nil, 2)
+		require.Equal(t, []testShard{
+			{Classes: []testClass{{Module: ":app", Name: "A"}, {Module: ":app", Name: "C"}, {Module: ":lib", Name: "E"}}, Duration: 3},
+			{Classes: []testClass{{Module: ":app", Name: "B"}, {Module: ":lib", Name: "D"}}, Duration: 2},
+		}, shards)
+	})
+
+	t.Run("with timings", func(t *testing.T) {
+		timings := map[string]float64{"A": 10, "B": 6, "C": 5, "D": 1}
+		shards := splitTestClasses(classes, timings, 2)
+		require.Equal(t, []testShard{
+			{Classes: []testClass{{Module: ":app", Name: "A"}, {Module: ":app", Name: "C"}}, Duration: 15},
+			{Classes: []testClass{{Module: ":app", Name: "B"}, {Module: ":lib", Name: "E"}, {Module: ":lib", Name: "D"}}, Duration: 12.5},
+		}, shards)
+	})
+
+	t.Run("more shards than classes", func(t *testing.T) {
+		shards := splitTestClasses(classes[:1], nil, 3)
+		require.Len(t, shards, 3)
+		require.Len(t, shards[0].Classes, 1)
+		require.Empty(t, shards[2].Classes)
+	})
+}
+
+func TestShardTasks(t *testing.T) {
+	got, err := shardTasks("testDebugUnitTest", []testClass{
+		{Module: ":lib", Name: "io.bitrise.LibTest"},
+		{Module: ":app", Name: "io.bitrise.MainTest"},
+		{Module: ":app", Name: "io.bitrise.AppTest"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, ":app:testDebugUnitTest --tests io.bitrise.AppTest --tests io.bitrise.MainTest :lib:testDebugUnitTest --tests io.bitrise.LibTest", got)
+
+	got, err = shardTasks("testDebugUnitTest", nil)
+	require.NoError(t, err)
+	require.Equal(t, "", got)
+
+	// Other tasks, qualified tasks and options are kept
+	got, err = shardTasks("assembleDebug testDebugUnitTest :core:testDebugUnitTest -x lint --stacktrace", []testClass{
+		{Module: ":app", Name: "io.bitrise.MainTest"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "assembleDebug :app:testDebugUnitTest --tests io.bitrise.MainTest :core:testDebugUnitTest -x lint --stacktrace", got)
+
+	got, err = shardTasks("assembleDebug testDebugUnitTest", nil)
+	require.NoError(t, err)
+	require.Equal(t, "assembleDebug", got)
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	keystoreFileName = "signing.keystore"
+
+	// The Android Gradle Plugin signs every variant with these properties (the IDE's "Generate Signed Bundle / APK" uses them too).
+	injectedSigningStoreFileProperty     = "android.injected.signing.store.file"
+	injectedSigningStorePasswordProperty = "android.injected.signing.store.password"
+	injectedSigningKeyAliasProperty      = "android.injected.signing.key.alias"
+	injectedSigningKeyPasswordProperty   = "android.injected.signing.key.password"
+
+	// keytool reads the keystore password from this environment variable, so it doesn't appear in the command.
+	keystorePasswordEnvKey = "BITRISE_GRADLE_KEYSTORE_PASSWORD"
+	// Gradle sets the project properties of the environment variables with this prefix.
+	gradleProjectPropertyEnvPrefix = "ORG_GRADLE_PROJECT_"
+
+	redactedValue = "[REDACTED]"
+
+	// APK Signature Scheme block ids, see https://source.android.com/docs/security/features/apksigning/v2
+	apkSignatureSchemeV2BlockID  = 0x7109871a
+	apkSignatureSchemeV3BlockID  = 0xf05368c0
+	apkSignatureSchemeV31BlockID = 0x1b93ad61
+	apkSigningBlockMagic         = "APK Sig Block 42"
+
+	zipEOCDSignature = 0x06054b50
+	zipEOCDSize      = 22
+)
+
+// secretGradleProperties are the project properties whose values are never printed.
+var secretGradleProperties = []string{injectedSigningStorePasswordProperty, injectedSigningKeyPasswordProperty}
+
+// redactArgs replaces the values of the secret project properties (-Pname=value or -P name=value) in the command args.
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+
+	for i, arg := range redacted {
+		prop := arg
+		if i > 0 && (redacted[i-1] == "-P" || redacted[i-1] == "--project-prop") {
+			prop = "-P" + arg
+		} else if strings.HasPrefix(arg, "--project-prop=") {
+			prop = "-P" + strings.TrimPrefix(arg, "--project-prop=")
+		}
+
+		for _, secret := range secretGradleProperties {
+			if strings.HasPrefix(prop, "-P"+secret+"=") {
+				redacted[i] = arg[:strings.Index(arg, secret+"=")+len(secret)+1] + redactedValue
+			}
+		}
+	}
+	return redacted
+}
+
+// signingArgs passes the keystore and the key alias to the Android Gradle Plugin, the passwords are passed by signingEnvs.
+func signingArgs(keystorePth, keyAlias string) []string {
+	return []string{
+		fmt.Sprintf("-P%s=%s", injectedSigningStoreFileProperty, keystorePth),
+		fmt.Sprintf("-P%s=%s", injectedSigningKeyAliasProperty, keyAlias),
+	}
+}
+
+// signingEnvs passes the passwords to the Android Gradle Plugin as ORG_GRADLE_PROJECT_ environment variables,
+// so they don't appear in the command line of the Gradle process.
+func signingEnvs(keystorePassword, keyPassword string) map[string]string {
+	return map[string]string{
+		gradleProjectPropertyEnvPrefix + injectedSigningStorePasswordProperty: keystorePassword,
+		gradleProjectPropertyEnvPrefix + injectedSigningKeyPasswordProperty:   keyPassword,
+	}
+}
+
+// isKeystore checks the magic number of the JKS and JCEKS keystores and the DER sequence of the PKCS12 ones.
+func isKeystore(content []byte) bool {
+	return bytes.HasPrefix(content, []byte{0xfe, 0xed, 0xfe, 0xed}) ||
+		bytes.HasPrefix(content, []byte{0xce, 0xce, 0xce, 0xce}) ||
+		bytes.HasPrefix(content, []byte{0x30, 0x82}) ||
+		bytes.HasPrefix(content, []byte{0x30, 0x83})
+}
+
+// prepareKeystore writes the keystore to pth. keystoreURL is a local path, a file:// or http(s):// URL, or the base64 encoded keystore.
+func prepareKeystore(keystoreURL, pth string) error {
+	switch {
+	case strings.HasPrefix(keystoreURL, "http://") || strings.HasPrefix(keystoreURL, "https://"):
+		if err := downloadFile(keystoreURL, pth); err != nil {
+			return err
+		}
+	case strings.HasPrefix(keystoreURL, "file://") || isFile(keystoreURL):
+		src := strings.TrimPrefix(keystoreURL, "file://")
+		if !isFile(src) {
+			return fmt.Errorf("keystore not found at: %s", src)
+		}
+		content, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(pth, content, 0600); err != nil {
+			return err
+		}
+	default:
+		// The input is not printed, it may be the keystore itself.
+		content, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(keystoreURL), ""))
+		if err != nil || !isKeystore(content) {
+			return errors.New("keystore_url is not an existing file, a file:// or http(s):// URL or a base64 encoded keystore")
+		}
+		if err := os.WriteFile(pth, content, 0600); err != nil {
+			return err
+		}
+	}
+	return os.Chmod(pth, 0600)
+}
+
+func removeKeystore(pth string) {
+	if err := os.Remove(pth); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warnf("Failed to remove the keystore (%s): %s", pth, err)
+	}
+}
+
+func keytoolPath() string {
+	if javaHome := os.Getenv("JAVA_HOME"); javaHome != "" {
+		if pth := filepath.Join(javaHome, "bin", "keytool"); isFile(pth) {
+			return pth
+		}
+	}
+	return "keytool"
+}
+
+// keystoreCertificate exports the DER encoded certificate of the key with keytool, which also checks the keystore password and the alias.
+func keystoreCertificate(keystorePth, keyAlias, keystorePassword string) ([]byte, error) {
+	cmd := command.New(keytoolPath(), "-exportcert", "-rfc", "-keystore", keystorePth, "-alias", keyAlias, "-storepass:env", keystorePasswordEnvKey)
+	cmd.AppendEnvs(keystorePasswordEnvKey + "=" + keystorePassword)
+	output, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", err, output)
+	}
+
+	// keytool may print warnings (like the one about the proprietary JKS format) before the certificate.
+	var block *pem.Block
+	if i := strings.Index(output, "-----BEGIN"); i != -1 {
+		block, _ = pem.Decode([]byte(output[i:]))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("no certificate in keytool output: %s", output)
+	}
+	return block.Bytes, nil
+}
+
+// certificateFingerprint returns the SHA-256 fingerprint of the DER encoded certificate, like apksigner and keytool print it.
+func certificateFingerprint(cert []byte) string {
+	sum := sha256.Sum256(cert)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// lengthPrefixed splits the uint32 length-prefixed value of the APK signing block off b.
+func lengthPrefixed(b []byte) ([]byte, []byte, error) {
+	if len(b) < 4 {
+		return nil, nil, errors.New("invalid APK signing block")
+	}
+	length := binary.LittleEndian.Uint32(b)
+	if uint64(len(b)-4) < uint64(length) {
+		return nil, nil, errors.New("invalid APK signing block")
+	}
+	return b[4 : 4+length], b[4+length:], nil
+}
+
+// apkSigningBlock returns the id-value pairs of the APK signing block, which is right before the zip central directory.
+func apkSigningBlock(f io.ReaderAt, size int64) (map[uint32][]byte, error) {
+	tailSize := int64(0xffff + zipEOCDSize)
+	if tailSize > size {
+		tailSize = size
+	}
+	tail := make([]byte, tailSize)
+	if _, err := f.ReadAt(tail, size-tailSize); err != nil {
+		return nil, err
+	}
+
+	eocd := -1
+	for i := len(tail) - zipEOCDSize; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(tail[i:]) == zipEOCDSignature {
+			eocd = i
+			break
+		}
+	}
+	if eocd == -1 {
+		return nil, errors.New("not a zip file")
+	}
+	centralDirOffset := int64(binary.LittleEndian.Uint32(tail[eocd+16:]))
+	if centralDirOffset < 24 {
+		return nil, nil
+	}
+
+	footer := make([]byte, 24)
+	if _, err := f.ReadAt(footer, centralDirOffset-24); err != nil {
+		return nil, err
+	}
+	if string(footer[8:]) != apkSigningBlockMagic {
+		return nil, nil
+	}
+	blockSize := int64(binary.LittleEndian.Uint64(footer))
+	if blockSize < 24 || blockSize+8 > centralDirOffset {
+		return nil, errors.New("invalid APK signing block")
+	}
+
+	pairs := make([]byte, blockSize-24)
+	if _, err := f.ReadAt(pairs, centralDirOffset-blockSize); err != nil {
+		return nil, err
+	}
+
+	values := map[uint32][]byte{}
+	for len(pairs) > 0 {
+		if len(pairs) < 12 {
+			return nil, errors.New("invalid APK signing block")
+		}
+		length := binary.LittleEndian.Uint64(pairs)
+		if length < 4 || length > uint64(len(pairs)-8) {
+			return nil, errors.New("invalid APK signing block")
+		}
+		values[binary.LittleEndian.Uint32(pairs[8:])] = pairs[12 : 8+length]
+		pairs = pairs[8+length:]
+	}
+	return values, nil
+}
+
+// apkSignatureSchemeCertificates returns the first certificate of each signer of an APK Signature Scheme v2/v3 block.
+func apkSignatureSchemeCertificates(block []byte) ([][]byte, error) {
+	signers, _, err := lengthPrefixed(block)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs [][]byte
+	for len(signers) > 0 {
+		var signer, signedData, certificates, cert []byte
+		if signer, signers, err = lengthPrefixed(signers); err != nil {
+			return nil, err
+		}
+		if signedData, _, err = lengthPrefixed(signer); err != nil {
+			return nil, err
+		}
+		// The signed data starts with the digests, followed by the certificates.
+		if _, signedData, err = lengthPrefixed(signedData); err != nil {
+			return nil, err
+		}
+		if certificates, _, err = lengthPrefixed(signedData); err != nil {
+			return nil, err
+		}
+		if cert, _, err = lengthPrefixed(certificates); err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+// pkcs7Certificates returns the DER encoded certificates of a PKCS #7 SignedData, like the META-INF/CERT.RSA of a JAR signature.
+func pkcs7Certificates(content []byte) ([][]byte, error) {
+	var contentInfo pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(content, &contentInfo); err != nil {
+		return nil, err
+	}
+	var signedData pkcs7SignedData
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return nil, err
+	}
+
+	var certs [][]byte
+	for rest := signedData.Certificates.Bytes; len(rest) > 0; {
+		var cert asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &cert); err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert.FullBytes)
+	}
+	return certs, nil
+}
+
+// jarSignatureCertificates returns the certificates of the JAR signatures (APK Signature Scheme v1, App Bundles).
+func jarSignatureCertificates(pth string) ([][]byte, error) {
+	r, err := zip.OpenReader(pth)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			log.Warnf("Failed to close %s: %s", pth, err)
+		}
+	}()
+
+	var certs [][]byte
+	for _, f := range r.File {
+		dir, name := filepath.Split(f.Name)
+		switch strings.ToUpper(filepath.Ext(name)) {
+		case ".RSA", ".DSA", ".EC":
+		default:
+			continue
+		}
+		if dir != "META-INF/" {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(rc)
+		if closeErr := rc.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		signatureCerts, err := pkcs7Certificates(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		if len(signatureCerts) > 0 {
+			certs = append(certs, signatureCerts[0])
+		}
+	}
+	return certs, nil
+}
+
+// artifactCertificates returns the signer certificates of the APK (v3, v2 or v1 signature) or the AAB (JAR signature).
+func artifactCertificates(pth string) ([][]byte, error) {
+	if strings.ToLower(filepath.Ext(pth)) == ".apk" {
+		f, err := os.Open(pth)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				log.Warnf("Failed to close %s: %s", pth, err)
+			}
+		}()
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+
+		block, err := apkSigningBlock(f, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range []uint32{apkSignatureSchemeV31BlockID, apkSignatureSchemeV3BlockID, apkSignatureSchemeV2BlockID} {
+			if value, ok := block[id]; ok {
+				return apkSignatureSchemeCertificates(value)
+			}
+		}
+	}
+
+	return jarSignatureCertificates(pth)
+}
+
+// verifyArtifactSignatures checks that the APKs and AABs are signed with the certificate of the keystore.
+func verifyArtifactSignatures(artifacts []string, cert []byte) error {
+	var problems []string
+	for _, artifact := range artifacts {
+		certs, err := artifactCertificates(artifact)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", filepath.Base(artifact), err))
+			continue
+		}
+		if len(certs) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: not signed", filepath.Base(artifact)))
+			continue
+		}
+
+		for _, artifactCert := range certs {
+			if !bytes.Equal(artifactCert, cert) {
+				problems = append(problems, fmt.Sprintf("%s: signed with certificate SHA-256 %s", filepath.Base(artifact), certificateFingerprint(artifactCert)))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigningArgs(t *testing.T) {
+	require.Equal(t, []string{
+		"-Pandroid.injected.signing.store.file=/tmp/signing.keystore",
+		"-Pandroid.injected.signing.key.alias=upload",
+	}, signingArgs("/tmp/signing.keystore", "upload"))
+	require.Equal(t, map[string]string{
+		"ORG_GRADLE_PROJECT_android.injected.signing.store.password": "store secret",
+		"ORG_GRADLE_PROJECT_android.injected.signing.key.password":   "key secret",
+	}, signingEnvs("store secret", "key secret"))
+}
+
+func TestRedactArgs(t *testing.T) {
+	args := []string{
+		"./gradlew", "assembleRelease",
+		"-Pandroid.injected.signing.store.file=/tmp/signing.keystore",
+		"-Pandroid.injected.signing.store.password=store secret",
+		"-P", "android.injected.signing.key.password=other",
+		"--project-prop=android.injected.signing.store.password=other",
+	}
+
+	require.Equal(t, []string{
+		"./gradlew", "assembleRelease",
+		"-Pandroid.injected.signing.store.file=/tmp/signing.keystore",
+		"-Pandroid.injected.signing.store.password=[REDACTED]",
+		"-P", "android.injected.signing.key.password=[REDACTED]",
+		"--project-prop=android.injected.signing.store.password=[REDACTED]",
+	}, redactArgs(args))
+	require.Equal(t, "-Pandroid.injected.signing.store.password=store secret", args[3])
+}
+
+func TestPrepareKeystore(t *testing.T) {
+	dir := t.TempDir()
+	keystore := append([]byte{0xfe, 0xed, 0xfe, 0xed}, []byte("keystore")...)
+	keystorePth := filepath.Join(dir, "release.jks")
+	require.NoError(t, os.WriteFile(keystorePth, keystore, 0644))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/release.jks" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(keystore)
+	}))
+	defer server.Close()
+
+	for _, keystoreURL := range []string{
+		keystorePth,
+		"file://" + keystorePth,
+		server.URL + "/release.jks",
+		base64.StdEncoding.EncodeToString(keystore),
+	} {
+		pth := filepath.Join(dir, keystoreFileName)
+		require.NoError(t, prepareKeystore(keystoreURL, pth), keystoreURL)
+
+		content, err := os.ReadFile(pth)
+		require.NoError(t, err)
+		require.Equal(t, keystore, content)
+		info, err := os.Stat(pth)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		removeKeystore(pth)
+		require.NoFileExists(t, pth)
+	}
+
+	pth := filepath.Join(dir, keystoreFileName)
+	require.Error(t, prepareKeystore(server.URL+"/missing.jks", pth))
+	require.EqualError(t, prepareKeystore("file://"+filepath.Join(dir, "missing.jks"), pth), "keystore not found at: "+filepath.Join(dir, "missing.jks"))
+	require.EqualError(t, prepareKeystore(filepath.Join(dir, "missing.jks"), pth),
+		"keystore_url is not an existing file, a file:// or http(s):// URL or a base64 encoded keystore")
+	require.Error(t, prepareKeystore(base64.StdEncoding.EncodeToString([]byte("not a keystore")), pth))
+}
+
+func createTestCertificate(t *testing.T, name string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return cert
+}
+
+// createPKCS7 creates a PKCS #7 SignedData with the certificate, without signer infos.
+func createPKCS7(t *testing.T, cert []byte) []byte {
+	certificates, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: cert})
+	require.NoError(t, err)
+	emptySet, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true})
+	require.NoError(t, err)
+	data, err := asn1.Marshal(struct{ ContentType asn1.ObjectIdentifier }{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}})
+	require.NoError(t, err)
+
+	signedData, err := asn1.Marshal(pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: asn1.RawValue{FullBytes: emptySet},
+		ContentInfo:      asn1.RawValue{FullBytes: data},
+		Certificates:     asn1.RawValue{FullBytes: certificates},
+		CRLs:             asn1.RawValue{FullBytes: []byte{}},
+		SignerInfos:      asn1.RawValue{FullBytes: emptySet},
+	})
+	require.NoError(t, err)
+
+	contentInfo, err := asn1.Marshal(pkcs7ContentInfo{
+		ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2},
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData},
+	})
+	require.NoError(t, err)
+	return contentInfo
+}
+
+func lengthPrefix(values ...[]byte) []byte {
+	var b []byte
+	for _, value := range values {
+		b = append(b, le32(uint32(len(value)))...)
+		b = append(b, value...)
+	}
+	return b
+}
+
+// createSignedAPK writes an APK with an APK Signature Scheme block of the certificate before the central directory.
+func createSignedAPK(t *testing.T, pth string, blockID uint32, cert []byte) {
+	writeZip(t, pth, map[string][]byte{apkManifestPath: []byte("manifest")})
+	content, err := os.ReadFile(pth)
+	require.NoError(t, err)
+
+	signedData := lengthPrefix(nil, lengthPrefix(cert), nil)
+	signer := lengthPrefix(signedData, nil, []byte("public key"))
+	value := lengthPrefix(lengthPrefix(signer))
+
+	pair := make([]byte, 8)
+	binary.LittleEndian.PutUint64(pair, uint64(4+len(value)))
+	pair = append(append(pair, le32(blockID)...), value...)
+	size := make([]byte, 8)
+	binary.LittleEndian.PutUint64(size, uint64(len(pair)+8+len(apkSigningBlockMagic)))
+	block := append(append(append(append([]byte{}, size...), pair...), size...), apkSigningBlockMagic...)
+
+	eocd := bytes.LastIndex(content, le32(zipEOCDSignature))
+	centralDirOffset := binary.LittleEndian.Uint32(content[eocd+16:])
+	binary.LittleEndian.PutUint32(content[eocd+16:], centralDirOffset+uint32(len(block)))
+	signed := append(append(append([]byte{}, content[:centralDirOffset]...), block...), content[centralDirOffset:]...)
+	require.NoError(t, os.WriteFile(pth, signed, 0644))
+}
+
+func TestVerifyArtifactSignatures(t *testing.T) {
+	dir := t.TempDir()
+	cert := createTestCertificate(t, "upload")
+	otherCert := createTestCertificate(t, "debug")
+
+	certs, err := pkcs7Certificates(createPKCS7(t, cert))
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{cert}, certs)
+
+	v2Pth := filepath.Join(dir, "app-v2.apk")
+	createSignedAPK(t, v2Pth, apkSignatureSchemeV2BlockID, cert)
+	v3Pth := filepath.Join(dir, "app-v3.apk")
+	createSignedAPK(t, v3Pth, apkSignatureSchemeV3BlockID, otherCert)
+	aabPth := filepath.Join(dir, "app.aab")
+	writeZip(t, aabPth, map[string][]byte{
+		aabManifestPath:       []byte("manifest"),
+		"META-INF/UPLOAD.SF":  []byte("signature file"),
+		"META-INF/UPLOAD.RSA": createPKCS7(t, cert),
+	})
+	unsignedPth := filepath.Join(dir, "app-unsigned.apk")
+	writeZip(t, unsignedPth, map[string][]byte{apkManifestPath: []byte("manifest")})
+
+	require.NoError(t, verifyArtifactSignatures([]string{v2Pth, aabPth}, cert))
+	require.EqualError(t, verifyArtifactSignatures([]string{v2Pth, v3Pth, unsignedPth}, cert),
+		"app-v3.apk: signed with certificate SHA-256 "+certificateFingerprint(otherCert)+"\n"+
+			"app-unsigned.apk: not signed")
+}
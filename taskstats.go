@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	taskEventsInitScriptName = "task-events.init.gradle"
+	taskEventsFileName       = "task-events.jsonl"
+	taskOutcomesFileName     = "gradle-task-outcomes.json"
+
+	tasksActionableEnvKey = "BITRISE_GRADLE_TASKS_ACTIONABLE"
+	tasksExecutedEnvKey   = "BITRISE_GRADLE_TASKS_EXECUTED"
+	tasksFromCacheEnvKey  = "BITRISE_GRADLE_TASKS_FROM_CACHE"
+	tasksUpToDateEnvKey   = "BITRISE_GRADLE_TASKS_UP_TO_DATE"
+	cacheHitRatioEnvKey   = "BITRISE_GRADLE_CACHE_HIT_RATIO"
+	taskOutcomesEnvKey    = "BITRISE_GRADLE_TASK_OUTCOMES_PATH"
+)
+
+// The init script registers a build service listening to the task finish events (Gradle 6.1+),
+// which is compatible with the configuration cache, unlike the TaskExecutionListener API.
+// Each finished task is appended as a JSON line to the output file, along with its task dependencies.
+const taskEventsInitScriptTemplate = `import groovy.json.JsonOutput
+import org.gradle.api.provider.Property
+import org.gradle.api.services.BuildService
+import org.gradle.api.services.BuildServiceParameters
+import org.gradle.build.event.BuildEventsListenerRegistry
+import org.gradle.tooling.events.FinishEvent
+import org.gradle.tooling.events.OperationCompletionListener
+import org.gradle.tooling.events.task.TaskFailureResult
+import org.gradle.tooling.events.task.TaskFinishEvent
+import org.gradle.tooling.events.task.TaskOperationDescriptor
+import org.gradle.tooling.events.task.TaskSkippedResult
+import org.gradle.tooling.events.task.TaskSuccessResult
+
+import javax.inject.Inject
+
+interface BitriseTaskEventsParameters extends BuildServiceParameters {
+    Property<String> getOutputPath()
+}
+
+abstract class BitriseTaskEventsService implements BuildService<BitriseTaskEventsParameters>, OperationCompletionListener {
+    @Override
+    void onFinish(FinishEvent event) {
+        if (!(event instanceof TaskFinishEvent)) {
+            return
+        }
+
+        def result = event.result
+        def outcome = 'EXECUTED'
+        if (result instanceof TaskFailureResult) {
+            outcome = 'FAILED'
+        } else if (result instanceof TaskSkippedResult) {
+            outcome = result.skipMessage
+        } else if (result instanceof TaskSuccessResult) {
+            if (result.fromCache) {
+                outcome = 'FROM-CACHE'
+            } else if (result.upToDate) {
+                outcome = 'UP-TO-DATE'
+            }
+        }
+
+        def dependencies = []
+        try {
+            dependencies = event.descriptor.dependencies.findAll { it instanceof TaskOperationDescriptor }.collect { it.taskPath }
+        } catch (Exception ignored) {
+            // Task dependencies are not available in every Gradle version
+        }
+
+        def record = [
+            path: event.descriptor.taskPath,
+            outcome: outcome,
+            start: result.startTime,
+            end: result.endTime,
+            dependencies: dependencies,
+        ]
+        synchronized (BitriseTaskEventsService) {
+            new File(parameters.outputPath.get()).append(JsonOutput.toJson(record) + '\n')
+        }
+    }
+}
+
+class BitriseTaskEventsPlugin implements Plugin<Gradle> {
+    private final BuildEventsListenerRegistry registry
+
+    @Inject
+    BitriseTaskEventsPlugin(BuildEventsListenerRegistry registry) {
+        this.registry = registry
+    }
+
+    @Override
+    void apply(Gradle gradle) {
+        def service = gradle.sharedServices.registerIfAbsent('bitriseTaskEvents', BitriseTaskEventsService) { spec ->
+            spec.parameters.outputPath.set({{OUTPUT_PATH}})
+        }
+        registry.onTaskCompletion(service)
+    }
+}
+
+apply plugin: BitriseTaskEventsPlugin
+`
+
+// Gradle prints the task outcome summary at the end of the build, like:
+// 37 actionable tasks: 12 executed, 20 from cache, 5 up-to-date
+var actionableTasksRegexp = regexp.MustCompile(`(?m)^(\d+) actionable tasks?: (.+)$`)
+
+type taskEvent struct {
+	Path         string   `json:"path"`
+	Outcome      string   `json:"outcome"`
+	Start        int64    `json:"start"`
+	End          int64    `json:"end"`
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+type taskOutcomeStats struct {
+	Actionable int
+	Executed   int
+	FromCache  int
+	UpToDate   int
+}
+
+// cacheHitRatio is the ratio of the tasks loaded from the build cache
+// among the tasks which had to be executed or loaded from the cache.
+func (s taskOutcomeStats) cacheHitRatio() float64 {
+	if s.Executed+s.FromCache == 0 {
+		return 0
+	}
+	return float64(s.FromCache) / float64(s.Executed+s.FromCache)
+}
+
+func taskEventsInitScript(outputPth string) string {
+	return strings.ReplaceAll(taskEventsInitScriptTemplate, "{{OUTPUT_PATH}}", groovyString(outputPth))
+}
+
+func parseActionableTasks(output string) (taskOutcomeStats, bool) {
+	matches := actionableTasksRegexp.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return taskOutcomeStats{}, false
+	}
+
+	// Use the last summary, composite builds might print one per build.
+	match := matches[len(matches)-1]
+	var stats taskOutcomeStats
+	stats.Actionable, _ = strconv.Atoi(match[1])
+
+	for _, part := range strings.Split(match[2], ",") {
+		fields := strings.SplitN(strings.TrimSpace(part), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		count, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+
+		switch strings.TrimSpace(fields[1]) {
+		case "executed":
+			stats.Executed = count
+		case "from cache":
+			stats.FromCache = count
+		case "up-to-date":
+			stats.UpToDate = count
+		}
+	}
+
+	return stats, true
+}
+
+func readTaskEvents(pth string) ([]taskEvent, error) {
+	f, err := os.Open(pth)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Warnf("Failed to close %s: %s", pth, err)
+		}
+	}()
+
+	var events []taskEvent
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var event taskEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			return nil, fmt.Errorf("failed to parse task event (%s): %w", line, err)
+		}
+		events = append(events, event)
+	}
+
+	return events, scanner.Err()
+}
+
+func taskOutcomeStatsFromEvents(events []taskEvent) taskOutcomeStats {
+	var stats taskOutcomeStats
+	for _, event := range events {
+		switch event.Outcome {
+		case "EXECUTED", "FAILED":
+			stats.Executed++
+		case "FROM-CACHE":
+			stats.FromCache++
+		case "UP-TO-DATE":
+			stats.UpToDate++
+		default:
+			continue
+		}
+		stats.Actionable++
+	}
+	return stats
+}
+
+func processTaskOutcomes(gradleOutput string, events []taskEvent, deployDir string) error {
+	stats, ok := parseActionableTasks(gradleOutput)
+	if !ok {
+		if len(events) == 0 {
+			log.Printf("No task outcome summary found in the Gradle output")
+			return nil
+		}
+		stats = taskOutcomeStatsFromEvents(events)
+	}
+
+	log.Printf("Actionable tasks: %d, executed: %d, from cache: %d, up-to-date: %d, cache hit ratio: %.2f",
+		stats.Actionable, stats.Executed, stats.FromCache, stats.UpToDate, stats.cacheHitRatio())
+
+	outputs := map[string]string{
+		tasksActionableEnvKey: strconv.Itoa(stats.Actionable),
+		tasksExecutedEnvKey:   strconv.Itoa(stats.Executed),
+		tasksFromCacheEnvKey:  strconv.Itoa(stats.FromCache),
+		tasksUpToDateEnvKey:   strconv.Itoa(stats.UpToDate),
+		cacheHitRatioEnvKey:   fmt.Sprintf("%.2f", stats.cacheHitRatio()),
+	}
+
+	if len(events) > 0 {
+		content, err := json.MarshalIndent(events, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		outcomesPth := filepath.Join(deployDir, taskOutcomesFileName)
+		if err := os.WriteFile(outcomesPth, content, 0644); err != nil {
+			return fmt.Errorf("failed to write task outcomes: %w", err)
+		}
+		outputs[taskOutcomesEnvKey] = outcomesPth
+	}
+
+	for key, value := range outputs {
+		if err := exportEnvironmentWithEnvman(key, value); err != nil {
+			return fmt.Errorf("failed to export environment (%s): %w", key, err)
+		}
+	}
+	log.Donef("The task outcomes are now available in the Environment Variables")
+
+	return nil
+}
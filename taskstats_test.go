@@ -0,0 +1,91 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseActionableTasks(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   taskOutcomeStats
+		wantOk bool
+	}{
+		{
+			name: "all outcomes",
+			output: `> Task :app:assembleDebug
+
+BUILD SUCCESSFUL in 1m 2s
+37 actionable tasks: 12 executed, 20 from cache, 5 up-to-date
+`,
+			want:   taskOutcomeStats{Actionable: 37, Executed: 12, FromCache: 20, UpToDate: 5},
+			wantOk: true,
+		},
+		{
+			name:   "single task",
+			output: "BUILD SUCCESSFUL in 3s\n1 actionable task: 1 executed\n",
+			want:   taskOutcomeStats{Actionable: 1, Executed: 1},
+			wantOk: true,
+		},
+		{
+			name:   "failed build",
+			output: "BUILD FAILED in 10s\n12 actionable tasks: 3 executed, 9 up-to-date",
+			want:   taskOutcomeStats{Actionable: 12, Executed: 3, UpToDate: 9},
+			wantOk: true,
+		},
+		{
+			name:   "no summary",
+			output: "FAILURE: Build failed with an exception.",
+			wantOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseActionableTasks(tt.output)
+			require.Equal(t, tt.wantOk, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTaskOutcomeStatsFromEvents(t *testing.T) {
+	events := []taskEvent{
+		{Path: ":app:compileKotlin", Outcome: "EXECUTED"},
+		{Path: ":app:compileJava", Outcome: "NO-SOURCE"},
+		{Path: ":app:mergeResources", Outcome: "FROM-CACHE"},
+		{Path: ":app:lint", Outcome: "FAILED"},
+		{Path: ":app:preBuild", Outcome: "UP-TO-DATE"},
+		{Path: ":app:clean", Outcome: "SKIPPED"},
+	}
+
+	stats := taskOutcomeStatsFromEvents(events)
+	require.Equal(t, taskOutcomeStats{Actionable: 4, Executed: 2, FromCache: 1, UpToDate: 1}, stats)
+	require.InDelta(t, 1.0/3, stats.cacheHitRatio(), 0.0001)
+	require.Equal(t, 0.0, taskOutcomeStats{UpToDate: 3}.cacheHitRatio())
+}
+
+func TestReadTaskEvents(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), taskEventsFileName)
+	writeTestFile(t, pth, `{"path":":app:compileKotlin","outcome":"EXECUTED","start":1000,"end":3000}
+{"path":":app:mergeResources","outcome":"FROM-CACHE","start":1500,"end":1600}
+
+`)
+
+	events, err := readTaskEvents(pth)
+	require.NoError(t, err)
+	require.Equal(t, []taskEvent{
+		{Path: ":app:compileKotlin", Outcome: "EXECUTED", Start: 1000, End: 3000},
+		{Path: ":app:mergeResources", Outcome: "FROM-CACHE", Start: 1500, End: 1600},
+	}, events)
+}
+
+func TestTaskEventsInitScript(t *testing.T) {
+	script := taskEventsInitScript(`/tmp/it's a \dir/task-events.jsonl`)
+	require.True(t, strings.Contains(script, `spec.parameters.outputPath.set('/tmp/it\'s a \\dir/task-events.jsonl')`))
+	require.False(t, strings.Contains(script, "{{"))
+}
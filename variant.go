@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/kballard/go-shellquote"
+	"github.com/ryanuber/go-glob"
+)
+
+const (
+	artifactKindAPK  = "apk"
+	artifactKindAAB  = "aab"
+	artifactKindBoth = "both"
+)
+
+// The task lines of `gradle tasks --all`, like: app:assembleFreeDebug - Assembles main output for variant freeDebug
+var taskListLineRegexp = regexp.MustCompile(`^([A-Za-z][\w:.-]*)(?: - .*)?$`)
+
+// buildVariant is the Android variant composed of the module, build_type, product_flavors and artifact_kind inputs.
+type buildVariant struct {
+	// Module is the Gradle path of the module, like :app, empty for every module.
+	Module string
+	// Dir is the directory of the module, relative to the root project.
+	Dir          string
+	BuildType    string
+	Flavors      []string
+	ArtifactKind string
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func uncapitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// newBuildVariant returns the variant of the inputs, the module is looked up in the project model.
+func newBuildVariant(module, buildType, productFlavors, artifactKind string, model projectModel) (*buildVariant, error) {
+	variant := buildVariant{
+		BuildType: buildType,
+		Flavors: strings.FieldsFunc(productFlavors, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		}),
+		ArtifactKind: artifactKind,
+	}
+
+	if module == "" {
+		return &variant, nil
+	}
+
+	variant.Module = normalizeProjectPath(module)
+	var appModules []string
+	for _, m := range model.Modules {
+		if m.Path == variant.Module {
+			variant.Dir = m.Dir
+			return &variant, nil
+		}
+		if m.Type == moduleTypeAndroidApplication {
+			appModules = append(appModules, m.Path)
+		}
+	}
+	return nil, fmt.Errorf("module %s is not included in %s, the application modules are: %s", variant.Module, model.SettingsFile, strings.Join(appModules, ", "))
+}
+
+// Name returns the variant name, like freeStagingRelease.
+func (v buildVariant) Name() string {
+	return uncapitalize(v.flavorName() + capitalize(v.BuildType))
+}
+
+func (v buildVariant) flavorName() string {
+	var name string
+	for _, flavor := range v.Flavors {
+		name += capitalize(flavor)
+	}
+	return name
+}
+
+// Tasks returns the tasks building the artifacts of the variant, like :app:assembleFreeStagingRelease :app:bundleFreeStagingRelease.
+func (v buildVariant) Tasks() []string {
+	var tasks []string
+	for _, kind := range []struct{ kind, task string }{{artifactKindAPK, "assemble"}, {artifactKindAAB, "bundle"}} {
+		if v.ArtifactKind != kind.kind && v.ArtifactKind != artifactKindBoth {
+			continue
+		}
+
+		task := kind.task + capitalize(v.Name())
+		if v.Module != "" {
+			task = gradleTaskPath(v.Module, task)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// ArtifactPatterns returns the patterns of the variant's output directories, relative to the root project:
+// APKs are written to build/outputs/apk/freeStaging/release and AABs to build/outputs/bundle/freeStagingRelease.
+// Without a module the outputs of the root project and of every subproject match.
+func (v buildVariant) ArtifactPatterns() []string {
+	buildDirs := []string{"build", "*/build"}
+	if v.Module != "" {
+		buildDirs = []string{filepath.Join(v.Dir, "build")}
+	}
+
+	var patterns []string
+	for _, buildDir := range buildDirs {
+		if v.ArtifactKind == artifactKindAPK || v.ArtifactKind == artifactKindBoth {
+			patterns = append(patterns, filepath.Join(buildDir, "outputs", "apk", uncapitalize(v.flavorName()), v.BuildType, "*.apk"))
+		}
+		if v.ArtifactKind == artifactKindAAB || v.ArtifactKind == artifactKindBoth {
+			patterns = append(patterns, filepath.Join(buildDir, "outputs", "bundle", v.Name(), "*.aab"))
+		}
+	}
+	return patterns
+}
+
+// filterVariantArtifacts keeps the artifacts (found by the app file filters) in the output directories of the variant.
+func filterVariantArtifacts(buildRootAbs string, artifacts []string, variant buildVariant) []string {
+	var filtered []string
+	for _, artifact := range artifacts {
+		rel, err := filepath.Rel(buildRootAbs, artifact)
+		if err != nil {
+			continue
+		}
+		for _, pattern := range variant.ArtifactPatterns() {
+			if glob.Glob(pattern, rel) {
+				filtered = append(filtered, artifact)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// parseTaskList returns the task names of the `gradle tasks --all` output, like app:assembleDebug.
+func parseTaskList(output string) []string {
+	var tasks []string
+	for _, line := range strings.Split(output, "\n") {
+		if match := taskListLineRegexp.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			tasks = append(tasks, match[1])
+		}
+	}
+	return tasks
+}
+
+// availableTasks lists the tasks of the module (or of every module) by running `gradle tasks --all`,
+// the returned task names are relative to the module.
+func availableTasks(gradlePath, buildRootAbs, module, gradleOptions string, extraArgs []string) ([]string, error) {
+	options, err := shellquote.Split(gradleOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	tasksTask := "tasks"
+	if module != "" {
+		tasksTask = gradleTaskPath(module, "tasks")
+	}
+
+	args := append([]string{tasksTask, "--all", "--console=plain"}, options...)
+	args = append(args, extraArgs...)
+	output, err := command.New(gradlePath, args...).SetDir(buildRootAbs).RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", err, lastLines(output, 20))
+	}
+	return parseTaskList(output), nil
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(s, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validateVariantTasks checks that the tasks of the variant exist, listing the similar tasks (of the other variants) if they don't.
+func validateVariantTasks(variant buildVariant, available []string) error {
+	isAvailable := map[string]bool{}
+	for _, task := range available {
+		isAvailable[task] = true
+		// Without a module the tasks of the subprojects are listed with their path, like app:assembleDebug
+		if i := strings.LastIndex(task, ":"); i != -1 && variant.Module == "" {
+			isAvailable[task[i+1:]] = true
+		}
+	}
+
+	var problems []string
+	for _, task := range variant.Tasks() {
+		name := task[strings.LastIndex(task, ":")+1:]
+		if isAvailable[name] {
+			continue
+		}
+
+		prefix := strings.TrimSuffix(name, capitalize(variant.Name()))
+		var similar []string
+		for _, availableTask := range available {
+			if strings.HasPrefix(availableTask[strings.LastIndex(availableTask, ":")+1:], prefix) {
+				similar = append(similar, availableTask)
+			}
+		}
+		sort.Strings(similar)
+		problems = append(problems, fmt.Sprintf("task %s doesn't exist, the available %s tasks are: %s", task, prefix, strings.Join(similar, ", ")))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var variantTestModel = projectModel{
+	SettingsFile: "settings.gradle",
+	Modules: []projectModule{
+		{Path: ":", Dir: "."},
+		{Path: ":app", Dir: "app", Type: moduleTypeAndroidApplication},
+		{Path: ":wear", Dir: "apps/wear", Type: moduleTypeAndroidApplication},
+		{Path: ":core", Dir: "core", Type: moduleTypeAndroidLibrary},
+	},
+}
+
+func TestBuildVariant(t *testing.T) {
+	tests := []struct {
+		name         string
+		module       string
+		buildType    string
+		flavors      string
+		kind         string
+		wantName     string
+		wantTasks    []string
+		wantPatterns []string
+	}{
+		{
+			name:         "flavors, both artifacts",
+			module:       "app",
+			buildType:    "release",
+			flavors:      "free, staging",
+			kind:         artifactKindBoth,
+			wantName:     "freeStagingRelease",
+			wantTasks:    []string{":app:assembleFreeStagingRelease", ":app:bundleFreeStagingRelease"},
+			wantPatterns: []string{"app/build/outputs/apk/freeStaging/release/*.apk", "app/build/outputs/bundle/freeStagingRelease/*.aab"},
+		},
+		{
+			name:         "no flavors, custom module dir, APK only",
+			module:       ":wear",
+			buildType:    "debug",
+			kind:         artifactKindAPK,
+			wantName:     "debug",
+			wantTasks:    []string{":wear:assembleDebug"},
+			wantPatterns: []string{"apps/wear/build/outputs/apk/debug/*.apk"},
+		},
+		{
+			name:         "every module, AAB only",
+			buildType:    "release",
+			flavors:      "paid",
+			kind:         artifactKindAAB,
+			wantName:     "paidRelease",
+			wantTasks:    []string{"bundlePaidRelease"},
+			wantPatterns: []string{"build/outputs/bundle/paidRelease/*.aab", "*/build/outputs/bundle/paidRelease/*.aab"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variant, err := newBuildVariant(tt.module, tt.buildType, tt.flavors, tt.kind, variantTestModel)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantName, variant.Name())
+			require.Equal(t, tt.wantTasks, variant.Tasks())
+			require.Equal(t, tt.wantPatterns, variant.ArtifactPatterns())
+		})
+	}
+
+	_, err := newBuildVariant("tv", "release", "", artifactKindBoth, variantTestModel)
+	require.EqualError(t, err, "module :tv is not included in settings.gradle, the application modules are: :app, :wear")
+}
+
+func TestFilterVariantArtifacts(t *testing.T) {
+	root := "/project"
+	variant, err := newBuildVariant("app", "release", "free", artifactKindBoth, variantTestModel)
+	require.NoError(t, err)
+
+	artifacts := []string{
+		filepath.Join(root, "app/build/outputs/apk/free/release/app-free-release.apk"),
+		filepath.Join(root, "app/build/outputs/apk/free/debug/app-free-debug.apk"),
+		filepath.Join(root, "app/build/outputs/apk/paid/release/app-paid-release.apk"),
+		filepath.Join(root, "app/build/outputs/bundle/freeRelease/app-free-release.aab"),
+		filepath.Join(root, "core/build/outputs/apk/free/release/core-free-release.apk"),
+	}
+	require.Equal(t, []string{
+		filepath.Join(root, "app/build/outputs/apk/free/release/app-free-release.apk"),
+		filepath.Join(root, "app/build/outputs/bundle/freeRelease/app-free-release.aab"),
+	}, filterVariantArtifacts(root, artifacts, *variant))
+
+	variant, err = newBuildVariant("", "release", "", artifactKindAPK, variantTestModel)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(root, "build/outputs/apk/release/root-release.apk"),
+		filepath.Join(root, "apps/wear/build/outputs/apk/release/wear-release.apk"),
+	}, filterVariantArtifacts(root, []string{
+		filepath.Join(root, "build/outputs/apk/release/root-release.apk"),
+		filepath.Join(root, "apps/wear/build/outputs/apk/release/wear-release.apk"),
+		filepath.Join(root, "build/outputs/apk/debug/root-debug.apk"),
+	}, *variant))
+}
+
+func TestValidateVariantTasks(t *testing.T) {
+	output := `
+> Task :app:tasks
+
+------------------------------------------------------------
+Tasks runnable from project ':app'
+------------------------------------------------------------
+
+Build tasks
+-----------
+assemble - Assemble main outputs for all the variants.
+assembleFreeDebug - Assembles main output for variant freeDebug
+assembleFreeRelease - Assembles main output for variant freeRelease
+bundleFreeRelease - Assembles bundles for variant freeRelease
+compileFreeReleaseSources
+
+BUILD SUCCESSFUL in 1s
+`
+	available := parseTaskList(output)
+	require.Contains(t, available, "assembleFreeRelease")
+	require.Contains(t, available, "compileFreeReleaseSources")
+	require.NotContains(t, available, "Build")
+
+	variant, err := newBuildVariant("app", "release", "free", artifactKindBoth, variantTestModel)
+	require.NoError(t, err)
+	require.NoError(t, validateVariantTasks(*variant, available))
+
+	variant, err = newBuildVariant("app", "release", "paid", artifactKindAPK, variantTestModel)
+	require.NoError(t, err)
+	require.EqualError(t, validateVariantTasks(*variant, available),
+		"task :app:assemblePaidRelease doesn't exist, the available assemble tasks are: assemble, assembleFreeDebug, assembleFreeRelease")
+
+	// Without a module, the tasks of the subprojects are listed with their path
+	variant, err = newBuildVariant("", "release", "free", artifactKindAAB, variantTestModel)
+	require.NoError(t, err)
+	require.NoError(t, validateVariantTasks(*variant, []string{"app:bundleFreeRelease"}))
+}
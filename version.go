@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	versionInitScriptName = "version.init.gradle"
+
+	// maxVersionCode is the greatest versionCode Google Play accepts.
+	maxVersionCode = 2100000000
+)
+
+// The variant API of AGP 7.0+ (androidComponents.onVariants) sets the version of the variant outputs,
+// older AGP versions override it through the legacy applicationVariants API.
+const versionInitScriptTemplate = `gradle.allprojects { project ->
+    project.plugins.withId('com.android.application') {
+        def androidComponents = project.extensions.findByName('androidComponents')
+        if (androidComponents != null && androidComponents.metaClass.respondsTo(androidComponents, 'onVariants')) {
+            androidComponents.onVariants(androidComponents.selector().all()) { variant ->
+                variant.outputs.each { output ->
+{{OUTPUT}}
+                }
+            }
+        } else {
+            project.android.applicationVariants.all { variant ->
+                variant.outputs.all { output ->
+{{LEGACY_OUTPUT}}
+                }
+            }
+        }
+    }
+}
+`
+
+// resolveVersionCode returns the version code input increased by the offset, or 0 if the input is empty.
+func resolveVersionCode(input string, offset int) (int, error) {
+	if input == "" {
+		return 0, nil
+	}
+
+	code, err := strconv.Atoi(strings.TrimSpace(input))
+	if err != nil {
+		return 0, fmt.Errorf("version_code (%s) is not an integer", input)
+	}
+
+	code += offset
+	if code < 1 || code > maxVersionCode {
+		return 0, fmt.Errorf("version code (%d = version_code %s + version_code_offset %d) should be between 1 and %d", code, input, offset, maxVersionCode)
+	}
+	return code, nil
+}
+
+// versionInitScript sets the version code (if not 0) and the version name (if not empty) of every application module.
+func versionInitScript(versionCode int, versionName string) string {
+	var output, legacyOutput []string
+	indent := strings.Repeat(" ", 24)
+	if versionCode != 0 {
+		output = append(output, fmt.Sprintf("%soutput.versionCode.set(%d)", indent, versionCode))
+		legacyOutput = append(legacyOutput, fmt.Sprintf("%soutput.versionCodeOverride = %d", indent, versionCode))
+	}
+	if versionName != "" {
+		output = append(output, fmt.Sprintf("%soutput.versionName.set(%s)", indent, groovyString(versionName)))
+		legacyOutput = append(legacyOutput, fmt.Sprintf("%soutput.versionNameOverride = %s", indent, groovyString(versionName)))
+	}
+
+	return strings.NewReplacer(
+		"{{OUTPUT}}", strings.Join(output, "\n"),
+		"{{LEGACY_OUTPUT}}", strings.Join(legacyOutput, "\n"),
+	).Replace(versionInitScriptTemplate)
+}
+
+// verifyArtifactVersions checks the version of the APKs and AABs against the injected version code and name.
+func verifyArtifactVersions(artifacts []string, versionCode int, versionName string) error {
+	var problems []string
+	for _, artifact := range artifacts {
+		version, err := artifactManifestVersion(artifact)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", filepath.Base(artifact), err))
+			continue
+		}
+
+		if versionCode != 0 && version.Code != versionCode {
+			problems = append(problems, fmt.Sprintf("%s: versionCode is %d instead of %d", filepath.Base(artifact), version.Code, versionCode))
+		}
+		if versionName != "" && version.Name != versionName {
+			problems = append(problems, fmt.Sprintf("%s: versionName is %q instead of %q", filepath.Base(artifact), version.Name, versionName))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
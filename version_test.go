@@ -0,0 +1,252 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveVersionCode(t *testing.T) {
+	tests := []struct {
+		input   string
+		offset  int
+		want    int
+		wantErr bool
+	}{
+		{input: "", offset: 100, want: 0},
+		{input: "42", offset: 0, want: 42},
+		{input: " 42\n", offset: 1000, want: 1042},
+		{input: "1.2", wantErr: true},
+		{input: "0", wantErr: true},
+		{input: "2100000000", offset: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := resolveVersionCode(tt.input, tt.offset)
+		if tt.wantErr {
+			require.Error(t, err, tt.input)
+			continue
+		}
+		require.NoError(t, err, tt.input)
+		require.Equal(t, tt.want, got, tt.input)
+	}
+}
+
+func TestVersionInitScript(t *testing.T) {
+	script := versionInitScript(1042, "1.4.0 'beta'")
+	require.Contains(t, script, "output.versionCode.set(1042)")
+	require.Contains(t, script, `output.versionName.set('1.4.0 \'beta\'')`)
+	require.Contains(t, script, "output.versionCodeOverride = 1042")
+
+	script = versionInitScript(0, "1.4.0")
+	require.NotContains(t, script, "versionCode")
+	require.Contains(t, script, "output.versionNameOverride = '1.4.0'")
+}
+
+type axmlTestAttr struct {
+	name     uint32
+	rawValue uint32
+	dataType uint8
+	data     uint32
+}
+
+func le16(v int) []byte {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, uint16(v))
+	return b
+}
+
+func le32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return b
+}
+
+// buildBinaryXML builds a binary XML document with a manifest root element.
+func buildBinaryXML(utf8 bool, strs []string, resourceIDs []uint32, attrs []axmlTestAttr) []byte {
+	var data []byte
+	var offsets []byte
+	for _, s := range strs {
+		offsets = append(offsets, le32(uint32(len(data)))...)
+		if utf8 {
+			data = append(data, byte(len(s)), byte(len(s)))
+			data = append(data, s...)
+			data = append(data, 0)
+		} else {
+			units := utf16.Encode([]rune(s))
+			data = append(data, le16(len(units))...)
+			for _, u := range units {
+				data = append(data, le16(int(u))...)
+			}
+			data = append(data, 0, 0)
+		}
+	}
+	for len(data)%4 != 0 {
+		data = append(data, 0)
+	}
+	var flags uint32
+	if utf8 {
+		flags = axmlUTF8Flag
+	}
+	pool := append(le16(axmlStringPoolType), le16(28)...)
+	pool = append(pool, le32(uint32(28+len(offsets)+len(data)))...)
+	pool = append(pool, le32(uint32(len(strs)))...)
+	pool = append(pool, le32(0)...)
+	pool = append(pool, le32(flags)...)
+	pool = append(pool, le32(uint32(28+len(offsets)))...)
+	pool = append(pool, le32(0)...)
+	pool = append(append(pool, offsets...), data...)
+
+	resourceMap := append(le16(axmlResourceMapType), le16(8)...)
+	resourceMap = append(resourceMap, le32(uint32(8+4*len(resourceIDs)))...)
+	for _, id := range resourceIDs {
+		resourceMap = append(resourceMap, le32(id)...)
+	}
+
+	manifestIdx := uint32(0)
+	for i, s := range strs {
+		if s == "manifest" {
+			manifestIdx = uint32(i)
+		}
+	}
+	element := append(le16(axmlStartElementType), le16(16)...)
+	element = append(element, le32(uint32(16+20+20*len(attrs)))...)
+	element = append(element, le32(1)...)
+	element = append(element, le32(axmlNoIndex)...)
+	element = append(element, le32(axmlNoIndex)...)
+	element = append(element, le32(manifestIdx)...)
+	element = append(element, le16(20)...)
+	element = append(element, le16(20)...)
+	element = append(element, le16(len(attrs))...)
+	element = append(element, le16(0)...)
+	element = append(element, le16(0)...)
+	element = append(element, le16(0)...)
+	for _, attr := range attrs {
+		element = append(element, le32(axmlNoIndex)...)
+		element = append(element, le32(attr.name)...)
+		element = append(element, le32(attr.rawValue)...)
+		element = append(element, le16(8)...)
+		element = append(element, 0, attr.dataType)
+		element = append(element, le32(attr.data)...)
+	}
+
+	body := append(append(pool, resourceMap...), element...)
+	doc := append(le16(0x0003), le16(8)...)
+	doc = append(doc, le32(uint32(8+len(body)))...)
+	return append(doc, body...)
+}
+
+func protoVarint(v uint64) []byte {
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutUvarint(b, v)]
+}
+
+func protoBytesField(number int, b []byte) []byte {
+	return append(append(protoVarint(uint64(number<<3|2)), protoVarint(uint64(len(b)))...), b...)
+}
+
+func protoVarintField(number int, v uint64) []byte {
+	return append(protoVarint(uint64(number<<3)), protoVarint(v)...)
+}
+
+// buildProtoXML builds an aapt2 XmlNode with a manifest root element.
+func buildProtoXML(versionCode int, versionName string) []byte {
+	prim := protoVarintField(protoPrimitiveIntDecimal, uint64(versionCode))
+	item := protoBytesField(protoItemPrimitive, prim)
+
+	codeAttr := protoBytesField(1, []byte("http://schemas.android.com/apk/res/android"))
+	codeAttr = append(codeAttr, protoBytesField(protoXMLAttributeName, []byte("versionCode"))...)
+	codeAttr = append(codeAttr, protoBytesField(protoXMLAttributeValue, []byte("1"))...)
+	codeAttr = append(codeAttr, protoVarintField(protoXMLAttributeResourceID, versionCodeAttrID)...)
+	codeAttr = append(codeAttr, protoBytesField(protoXMLAttributeItem, item)...)
+
+	nameAttr := protoBytesField(protoXMLAttributeName, []byte("versionName"))
+	nameAttr = append(nameAttr, protoBytesField(protoXMLAttributeValue, []byte(versionName))...)
+	nameAttr = append(nameAttr, protoVarintField(protoXMLAttributeResourceID, versionNameAttrID)...)
+
+	packageAttr := protoBytesField(protoXMLAttributeName, []byte("package"))
+	packageAttr = append(packageAttr, protoBytesField(protoXMLAttributeValue, []byte("io.bitrise.sample"))...)
+
+	element := protoBytesField(protoXMLElementName, []byte("manifest"))
+	element = append(element, protoBytesField(protoXMLElementAttribute, packageAttr)...)
+	element = append(element, protoBytesField(protoXMLElementAttribute, codeAttr)...)
+	element = append(element, protoBytesField(protoXMLElementAttribute, nameAttr)...)
+	element = append(element, protoBytesField(5, protoBytesField(protoXMLNodeElement, protoBytesField(protoXMLElementName, []byte("application"))))...)
+
+	return protoBytesField(protoXMLNodeElement, element)
+}
+
+func TestParseBinaryXMLManifestVersion(t *testing.T) {
+	for _, utf8 := range []bool{true, false} {
+		// The attribute names are not always kept (resource shrinking), the resource ids identify them.
+		content := buildBinaryXML(utf8,
+			[]string{"", "versionName", "package", "manifest", "1.4.0", "io.bitrise.sample"},
+			[]uint32{versionCodeAttrID, versionNameAttrID},
+			[]axmlTestAttr{
+				{name: 0, rawValue: axmlNoIndex, dataType: axmlTypeIntDec, data: 1042},
+				{name: 1, rawValue: 4, dataType: axmlTypeString, data: 4},
+				{name: 2, rawValue: 5, dataType: axmlTypeString, data: 5},
+			})
+
+		version, err := parseBinaryXMLManifestVersion(content)
+		require.NoError(t, err)
+		require.Equal(t, &manifestVersion{Code: 1042, Name: "1.4.0"}, version)
+	}
+
+	_, err := parseBinaryXMLManifestVersion([]byte{3, 0, 8, 0, 255, 0, 0, 0, 1})
+	require.Error(t, err)
+}
+
+func TestParseProtoXMLManifestVersion(t *testing.T) {
+	version, err := parseProtoXMLManifestVersion(buildProtoXML(1042, "1.4.0"))
+	require.NoError(t, err)
+	require.Equal(t, &manifestVersion{Code: 1042, Name: "1.4.0"}, version)
+
+	_, err = parseProtoXMLManifestVersion([]byte{0x0a, 0x10, 0x01})
+	require.Error(t, err)
+}
+
+func writeZip(t *testing.T, pth string, entries map[string][]byte) {
+	f, err := os.Create(pth)
+	require.NoError(t, err)
+	w := zip.NewWriter(f)
+	for name, content := range entries {
+		entry, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = entry.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+}
+
+func TestVerifyArtifactVersions(t *testing.T) {
+	dir := t.TempDir()
+	apkPth := filepath.Join(dir, "app-release.apk")
+	writeZip(t, apkPth, map[string][]byte{
+		apkManifestPath: buildBinaryXML(true,
+			[]string{"versionCode", "versionName", "manifest", "1.4.0"},
+			[]uint32{versionCodeAttrID, versionNameAttrID},
+			[]axmlTestAttr{
+				{name: 0, rawValue: axmlNoIndex, dataType: axmlTypeIntDec, data: 1042},
+				{name: 1, rawValue: 3, dataType: axmlTypeString, data: 3},
+			}),
+	})
+	aabPth := filepath.Join(dir, "app-release.aab")
+	writeZip(t, aabPth, map[string][]byte{aabManifestPath: buildProtoXML(1041, "1.4.0")})
+
+	require.NoError(t, verifyArtifactVersions([]string{apkPth}, 1042, "1.4.0"))
+	require.NoError(t, verifyArtifactVersions([]string{apkPth, aabPth}, 0, "1.4.0"))
+	require.EqualError(t, verifyArtifactVersions([]string{apkPth, aabPth}, 1042, "1.4.1"),
+		"app-release.apk: versionName is \"1.4.0\" instead of \"1.4.1\"\n"+
+			"app-release.aab: versionCode is 1041 instead of 1042\n"+
+			"app-release.aab: versionName is \"1.4.0\" instead of \"1.4.1\"")
+
+	emptyPth := filepath.Join(dir, "empty.apk")
+	writeZip(t, emptyPth, map[string][]byte{"classes.dex": nil})
+	require.EqualError(t, verifyArtifactVersions([]string{emptyPth}, 1042, ""), "empty.apk: AndroidManifest.xml not found")
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	wrapperValidationOff  = "off"
+	wrapperValidationWarn = "warn"
+	wrapperValidationFail = "fail"
+
+	gradleDistributionsURL = "https://services.gradle.org/distributions"
+)
+
+//go:embed gradle-wrapper-checksums.txt
+var bundledWrapperChecksums string
+
+var sha256Regexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+func gradleWrapperJarPath(gradlewPath string) string {
+	return filepath.Join(filepath.Dir(gradlewPath), "gradle", "wrapper", "gradle-wrapper.jar")
+}
+
+// parseChecksums reads a list of SHA-256 checksums, separated by whitespace, # starts a comment.
+func parseChecksums(content string) map[string]bool {
+	checksums := map[string]bool{}
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		for _, field := range strings.Fields(line) {
+			if field = strings.ToLower(field); sha256Regexp.MatchString(field) {
+				checksums[field] = true
+			}
+		}
+	}
+	return checksums
+}
+
+func fileSHA256(pth string) (string, error) {
+	f, err := os.Open(pth)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Warnf("Failed to close %s: %s", pth, err)
+		}
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// fetchWrapperChecksum downloads the official checksum of the wrapper jar of a Gradle version.
+func fetchWrapperChecksum(distributionsURL, version string) (string, error) {
+	return fetchChecksum(fmt.Sprintf("%s/gradle-%s-wrapper.jar.sha256", strings.TrimSuffix(distributionsURL, "/"), version))
+}
+
+// fetchChecksum downloads a .sha256 file, like the ones published next to the Gradle distributions.
+func fetchChecksum(checksumURL string) (string, error) {
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(checksumURL)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warnf("Failed to close response body: %s", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", checksumURL, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+
+	checksum := strings.ToLower(strings.TrimSpace(string(body)))
+	if !sha256Regexp.MatchString(checksum) {
+		return "", fmt.Errorf("invalid checksum downloaded from %s", checksumURL)
+	}
+	return checksum, nil
+}
+
+// validateWrapperJar checks the wrapper jar against the known checksums,
+// and against the official checksum of the wrapper's Gradle version, if it is not a known one.
+func validateWrapperJar(jarPth string, knownChecksums map[string]bool, gradleVersion, distributionsURL string) error {
+	checksum, err := fileSHA256(jarPth)
+	if err != nil {
+		return fmt.Errorf("failed to read the wrapper jar: %w", err)
+	}
+
+	if knownChecksums[checksum] {
+		return nil
+	}
+
+	if gradleVersion == "" {
+		return fmt.Errorf("%s (SHA-256: %s) is not an official Gradle wrapper jar", jarPth, checksum)
+	}
+
+	officialChecksum, err := fetchWrapperChecksum(distributionsURL, gradleVersion)
+	if err != nil {
+		return fmt.Errorf("%s (SHA-256: %s) is not a known Gradle wrapper jar, and the official checksum is not available: %w", jarPth, checksum, err)
+	}
+	if officialChecksum != checksum {
+		return fmt.Errorf("%s (SHA-256: %s) doesn't match the official Gradle %s wrapper jar (SHA-256: %s)", jarPth, checksum, gradleVersion, officialChecksum)
+	}
+
+	return nil
+}
+
+// validateWrapperDistribution returns the problems of the wrapper's distribution settings:
+// a missing distributionSha256Sum or a distributionUrl host which is not allowed.
+func validateWrapperDistribution(properties map[string]string, allowedHosts []string) []string {
+	var problems []string
+
+	if strings.TrimSpace(properties["distributionSha256Sum"]) == "" {
+		problems = append(problems, "distributionSha256Sum is not set, the downloaded Gradle distribution is not verified")
+	}
+
+	distributionURL := properties["distributionUrl"]
+	u, err := url.Parse(distributionURL)
+	if err != nil || u.Hostname() == "" {
+		return append(problems, fmt.Sprintf("invalid distributionUrl: %s", distributionURL))
+	}
+
+	if len(allowedHosts) > 0 {
+		allowed := false
+		for _, host := range allowedHosts {
+			if strings.EqualFold(strings.TrimSpace(host), u.Hostname()) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			problems = append(problems, fmt.Sprintf("the host of distributionUrl (%s) is not allowed", u.Hostname()))
+		}
+	}
+
+	return problems
+}
+
+// validateWrapper validates the Gradle wrapper next to gradlewPath and returns the problems which should fail the build.
+func validateWrapper(gradlewPath string, configs Config) error {
+	if configs.WrapperValidation != wrapperValidationOff {
+		knownChecksums := parseChecksums(bundledWrapperChecksums + "\n" + configs.WrapperExtraChecksums)
+		gradleVersion, err := gradleWrapperVersion(gradlewPath)
+		if err != nil {
+			log.Warnf("Failed to get the wrapper's Gradle version: %s", err)
+		}
+
+		if err := validateWrapperJar(gradleWrapperJarPath(gradlewPath), knownChecksums, gradleVersion, gradleDistributionsURL); err != nil {
+			if configs.WrapperValidation == wrapperValidationFail {
+				return err
+			}
+			log.Warnf("%s", err)
+		} else {
+			log.Donef("Gradle wrapper jar is valid")
+		}
+	}
+
+	if configs.WrapperDistributionPolicy == wrapperValidationOff {
+		return nil
+	}
+
+	var problems []string
+	propertiesPth := gradleWrapperPropertiesPath(gradlewPath)
+	if properties, err := readProperties(propertiesPth); err != nil {
+		problems = append(problems, fmt.Sprintf("failed to read: %s", err))
+	} else {
+		problems = validateWrapperDistribution(properties, filterEmpty(strings.Split(configs.WrapperDistributionHosts, "\n")))
+	}
+
+	for _, problem := range problems {
+		log.Warnf("%s: %s", propertiesPth, problem)
+	}
+	if len(problems) > 0 && configs.WrapperDistributionPolicy == wrapperValidationFail {
+		return fmt.Errorf("the Gradle wrapper's distribution settings are not trusted")
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChecksums(t *testing.T) {
+	checksum := fmt.Sprintf("%x", sha256.Sum256([]byte("jar")))
+	require.Equal(t, map[string]bool{checksum: true}, parseChecksums("# comment\n\n"+checksum+"  # gradle-8.2\nnot-a-checksum\n"))
+	require.NotNil(t, parseChecksums(bundledWrapperChecksums))
+}
+
+func TestValidateWrapperJar(t *testing.T) {
+	jarPth := filepath.Join(t.TempDir(), "gradle-wrapper.jar")
+	writeTestFile(t, jarPth, "official jar")
+	checksum := fmt.Sprintf("%x", sha256.Sum256([]byte("official jar")))
+
+	distributions := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/gradle-8.2-wrapper.jar.sha256" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = fmt.Fprint(w, checksum)
+	}))
+	defer distributions.Close()
+
+	require.NoError(t, validateWrapperJar(jarPth, map[string]bool{checksum: true}, "", distributions.URL))
+	require.NoError(t, validateWrapperJar(jarPth, map[string]bool{}, "8.2", distributions.URL))
+	require.Error(t, validateWrapperJar(jarPth, map[string]bool{}, "7.6", distributions.URL))
+	require.Error(t, validateWrapperJar(jarPth, map[string]bool{}, "", distributions.URL))
+
+	writeTestFile(t, jarPth, "tampered jar")
+	err := validateWrapperJar(jarPth, map[string]bool{checksum: true}, "8.2", distributions.URL)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "doesn't match the official Gradle 8.2 wrapper jar")
+}
+
+func TestValidateWrapperDistribution(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties map[string]string
+		hosts      []string
+		want       []string
+	}{
+		{
+			name: "trusted",
+			properties: map[string]string{
+				"distributionUrl":       "https://services.gradle.org/distributions/gradle-8.2-bin.zip",
+				"distributionSha256Sum": "0000000000000000000000000000000000000000000000000000000000000000",
+			},
+			hosts: []string{"services.gradle.org"},
+		},
+		{
+			name: "missing checksum and unknown host",
+			properties: map[string]string{
+				"distributionUrl": "https://mirror.example.com/gradle-8.2-bin.zip",
+			},
+			hosts: []string{"services.gradle.org"},
+			want: []string{
+				"distributionSha256Sum is not set, the downloaded Gradle distribution is not verified",
+				"the host of distributionUrl (mirror.example.com) is not allowed",
+			},
+		},
+		{
+			name: "no allowlist",
+			properties: map[string]string{
+				"distributionUrl":       "https://mirror.example.com/gradle-8.2-bin.zip",
+				"distributionSha256Sum": "0000000000000000000000000000000000000000000000000000000000000000",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, validateWrapperDistribution(tt.properties, tt.hosts))
+		})
+	}
+}